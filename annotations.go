@@ -0,0 +1,176 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Returns whether the header is the one of an EDF+ or BDF+ file.
+func (h *Header) isPlus() bool {
+	return strings.HasPrefix(h.Reserved, "EDF+") || strings.HasPrefix(h.Reserved, "BDF+")
+}
+
+// IsDiscontinuous returns whether the header is the one of an EDF+D or BDF+D
+// file, whose data records are not necessarily contiguous in time.
+func (h *Header) IsDiscontinuous() bool {
+	return strings.HasPrefix(h.Reserved, "EDF+D") || strings.HasPrefix(h.Reserved, "BDF+D")
+}
+
+// AnnotationSignal returns the index of the first annotation signal, or -1 if
+// there is none. In EDF+ files, this signal holds the time-keeping annotations.
+func (h *Header) AnnotationSignal() int {
+	for i := range h.Signals {
+		if h.Signals[i].IsAnnotation() {
+			return i
+		}
+	}
+	return -1
+}
+
+// RecordOnset returns the start of the index-th data record, in seconds since
+// the start of the recording. Records of continuous files follow each other;
+// the onset of records of discontinuous files is read from the time-keeping
+// annotation at the start of their annotation signal.
+func (h *Header) RecordOnset(index int, record *Record) (float64, error) {
+	if !h.IsDiscontinuous() {
+		return float64(index) * float64(h.DurationDataRecords), nil
+	}
+	annotationSignal := h.AnnotationSignal()
+	if annotationSignal < 0 {
+		return 0, errors.New("Discontinuous file without annotation signal")
+	}
+	if annotationSignal >= len(record.Signals) {
+		return 0, fmt.Errorf("Record %d has no annotation signal", index)
+	}
+	tal := AnnotationBytes(h, &record.Signals[annotationSignal])
+	if end := bytes.IndexAny(tal, "\x14\x15"); end >= 0 {
+		tal = tal[:end]
+	}
+	onset, err := strconv.ParseFloat(string(tal), 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid time-keeping annotation in record %d: %v", index, err)
+	}
+	return onset, nil
+}
+
+// RecordOnsets returns the start of every data record, in seconds since the
+// start of the recording.
+func (e *Edf) RecordOnsets() ([]float64, error) {
+	onsets := make([]float64, len(e.Records))
+	for i := range e.Records {
+		onset, err := e.Header.RecordOnset(i, &e.Records[i])
+		if err != nil {
+			return nil, err
+		}
+		onsets[i] = onset
+	}
+	return onsets, nil
+}
+
+// AnnotationBytes returns the raw bytes of an annotation signal, which are
+// stored two (EDF) or three (BDF) per sample.
+func AnnotationBytes(h *Header, signal *SignalRecord) []byte {
+	sampleSize := h.SampleSize()
+	data := make([]byte, 0, sampleSize*len(signal.Samples))
+	for _, sample := range signal.Samples {
+		for b := 0; b < sampleSize; b++ {
+			data = append(data, byte(sample>>(8*uint(b))))
+		}
+	}
+	return data
+}
+
+// Stores the raw bytes of an annotation signal into its samples, padding them
+// with zeros.
+func setAnnotationBytes(h *Header, signal *SignalRecord, data []byte) error {
+	sampleSize := h.SampleSize()
+	if len(data) > sampleSize*len(signal.Samples) {
+		return fmt.Errorf("%d bytes of annotations do not fit in %d samples", len(data), len(signal.Samples))
+	}
+	shift := uint(32 - 8*sampleSize)
+	for i := range signal.Samples {
+		var sample uint32
+		for b := 0; b < sampleSize; b++ {
+			if k := i*sampleSize + b; k < len(data) {
+				sample |= uint32(data[k]) << (8 * uint(b))
+			}
+		}
+		// Sign extension, as when decoding samples.
+		signal.Samples[i] = int32(sample<<shift) >> shift
+	}
+	return nil
+}
+
+// Shifts the onsets of the time-stamped annotation lists (TALs) found in the
+// bytes of an annotation signal earlier by the given number of seconds.
+func shiftOnsets(data []byte, seconds int64) []byte {
+	var result []byte
+	for len(data) > 0 && data[0] != 0 {
+		end := bytes.Index(data, []byte{0x14, 0})
+		if end < 0 {
+			end = len(data)
+		} else {
+			end += 2
+		}
+		tal := data[:end]
+		data = data[end:]
+		onsetEnd := bytes.IndexAny(tal, "\x14\x15")
+		if onsetEnd < 0 {
+			result = append(result, tal...)
+			continue
+		}
+		result = append(result, shiftOnset(string(tal[:onsetEnd]), seconds)...)
+		result = append(result, tal[onsetEnd:]...)
+	}
+	return result
+}
+
+// Shifts an onset, such as +3600.25, earlier by the given number of seconds.
+// The fractional part is kept as is unless the onset changes sign.
+func shiftOnset(onset string, seconds int64) string {
+	if len(onset) < 2 || (onset[0] != '+' && onset[0] != '-') {
+		return onset
+	}
+	sign, digits, fraction := onset[:1], onset[1:], ""
+	if dot := strings.IndexByte(digits, '.'); dot >= 0 {
+		digits, fraction = digits[:dot], digits[dot:]
+	}
+	integer, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return onset
+	}
+	if sign == "+" {
+		integer -= seconds
+	} else {
+		integer += seconds
+	}
+	if integer >= 0 {
+		return sign + strconv.FormatInt(integer, 10) + fraction
+	}
+	value, err := strconv.ParseFloat(onset, 64)
+	if err != nil {
+		return onset
+	}
+	value -= float64(seconds)
+	if value >= 0 {
+		return "+" + strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,67 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+// Allocates n data records of the signals of the header, so that the samples
+// of each signal are contiguous across records.
+func (e *Edf) allocateRecords(n int) {
+	header := e.Header
+	records := make([]Record, n)
+	columns := make([][]int32, len(header.Signals))
+	for s := range columns {
+		columns[s] = make([]int32, n*int(header.Signals[s].SamplesRecord))
+	}
+	signals := make([]SignalRecord, n*len(header.Signals))
+	for i := range records {
+		records[i].Signals = signals[i*len(header.Signals) : (i+1)*len(header.Signals)]
+		for s := range records[i].Signals {
+			n := int(header.Signals[s].SamplesRecord)
+			records[i].Signals[s].Samples = columns[s][i*n : (i+1)*n : (i+1)*n]
+		}
+	}
+	e.Records, e.columns, e.columnRecords = records, columns, records
+}
+
+// Returns whether the records are still the ones allocated along with the
+// columns when reading the file. Only the slice of records is compared, so
+// that the check does not depend on their number.
+func (e *Edf) hasColumns() bool {
+	return len(e.columns) == len(e.Header.Signals) && len(e.Records) > 0 && len(e.columnRecords) >= len(e.Records) &&
+		&e.Records[0] == &e.columnRecords[0]
+}
+
+// Samples returns the samples of a signal across all data records, in a single
+// contiguous slice.
+//
+// Read stores the samples of each signal contiguously when the header declares
+// the number of data records and the size of the input is known to hold them,
+// as do RandomAccessReader.ReadAll,
+// RandomAccessReader.ReadRange and Merge, the data records being views into
+// them. For those, Samples does not copy: the returned slice shares its memory
+// with the data records, as long as the slice of data records is not replaced
+// and their samples are modified in place. Otherwise, for instance for data
+// records read one at a time by RecordReader, or read until the end of the
+// input, the samples are copied.
+func (e *Edf) Samples(signal int) []int32 {
+	n := int(e.Header.Signals[signal].SamplesRecord)
+	if e.hasColumns() {
+		return e.columns[signal][:len(e.Records)*n]
+	}
+	samples := make([]int32, 0, len(e.Records)*n)
+	for i := range e.Records {
+		samples = append(samples, e.Records[i].Signals[signal].Samples...)
+	}
+	return samples
+}
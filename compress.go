@@ -0,0 +1,51 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Returns a reader decompressing input if it starts with the magic bytes of a
+// gzip, bzip2 or zlib stream, or input itself otherwise. The first byte of EDF
+// and BDF files, '0' or 0xFF, does not match any of them.
+func decompress(input *bufio.Reader) (*bufio.Reader, bool, error) {
+	magic, err := input.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, false, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		r, err := gzip.NewReader(input)
+		if err != nil {
+			return nil, false, err
+		}
+		return bufio.NewReader(r), true, nil
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		return bufio.NewReader(bzip2.NewReader(input)), true, nil
+	case len(magic) >= 2 && magic[0]&0x0f == 8 && (uint(magic[0])<<8|uint(magic[1]))%31 == 0:
+		r, err := zlib.NewReader(input)
+		if err != nil {
+			return nil, false, err
+		}
+		return bufio.NewReader(r), true, nil
+	}
+	return input, false, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// testFile(1) compressed with bzip2, which the standard library cannot write.
+const bzip2TestFile = "" +
+	"\x42\x5a\x68\x39\x31\x41\x59\x26\x53\x59\x98\x06\x34\x26\x00\x00\x41\x5f\xa6\xf8\x40\x40\x03\x7d" +
+	"\xd4\x3b\xd1\x5d\x40\x2e\xe6\xde\x10\x04\x00\x00\x01\xe0\x00\x00\x00\xb0\x00\xb9\x31\x13\x4c\xa4" +
+	"\xd9\x4f\x28\xfd\x50\x00\x00\xc8\x0f\x53\x43\xda\xa0\x18\x0d\x01\xa0\x00\x0d\x34\x1a\x01\xa0\x0d" +
+	"\x03\x41\x13\x43\x53\xd4\xf2\x86\x83\x43\x40\x00\x00\x03\x5e\xf4\x22\x00\xaa\x7c\x4c\x5b\xf1\xa2" +
+	"\x4a\x6c\xe9\x7b\x56\xee\xb0\x5a\x60\x2d\x39\x09\xa6\x48\x64\x41\xc6\x00\xb1\xc0\x4c\x7c\xd8\x2e" +
+	"\x69\x4a\x9e\xf1\x18\x90\x94\xf8\xd8\xd3\x15\xa5\x0a\x82\xfd\x78\xa9\x92\xc2\x76\x50\x3f\x38\x28" +
+	"\x59\x9a\x2e\x08\x99\x99\x1a\xe8\xa4\x20\xc4\xd4\x80\x94\x09\x18\xd7\x1a\xf6\x48\x84\x11\xa1\x50" +
+	"\x80\xde\x89\xf0\xe3\x92\xd5\x4a\xf0\x66\x55\xb3\x7b\x66\xf3\x2d\xcd\xa7\xcc\xae\xbc\x6c\x9c\x8f" +
+	"\xa8\x41\x7c\x25\x07\xe9\xab\x37\x1b\x95\x61\x9e\x78\x4a\x4c\xfd\xf3\xef\xdf\x8b\xb9\x22\x9c\x28" +
+	"\x48\x4c\x03\x1a\x13\x00"
+
+func TestReadCompressed(t *testing.T) {
+	data, records := testFile(1)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "edf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.edf.gz")
+	if err := WriteEDF(filename, e); err != nil {
+		t.Fatal(err)
+	}
+	gzipped, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zlibbed := new(bytes.Buffer)
+	w := zlib.NewWriter(zlibbed)
+	w.Write(data)
+	w.Close()
+
+	for name, compressed := range map[string][]byte{
+		"gzip":  gzipped,
+		"bzip2": []byte(bzip2TestFile),
+		"zlib":  zlibbed.Bytes(),
+	} {
+		e, err := Read(bytes.NewReader(compressed))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(e.Records, records) {
+			t.Errorf("%s: %v should be equal to %v", name, e.Records, records)
+		}
+	}
+
+	problems, err := ValidateFile(filename)
+	if err != nil || len(problems) != 0 {
+		t.Errorf("Unexpected problems %v (%v)", problems, err)
+	}
+}
+
+func TestDecodeCompressedTwice(t *testing.T) {
+	data, records := testFile(1)
+	compressed := new(bytes.Buffer)
+	w := gzip.NewWriter(compressed)
+	w.Write(data)
+	w.Write(data)
+	w.Close()
+
+	d := NewDecoder(compressed)
+	for i := 0; i < 2; i++ {
+		e, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Records, records) {
+			t.Errorf("%v should be equal to %v", e.Records, records)
+		}
+	}
+	if _, err := d.Decode(); err == nil {
+		t.Error("Decoding past the end of the input should fail")
+	}
+}
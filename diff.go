@@ -0,0 +1,168 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A Difference is a field or a signal whose value differs between two EDF
+// files.
+type Difference struct {
+	// Field is the name of the header field, "Signals" for a signal defined in
+	// only one of the files, or "Samples" for the samples of a signal.
+	Field string
+	// Signal is the index of the signal, or -1 for a field of the file.
+	Signal int
+	// A and B are the values in the two files, as written in the header for
+	// fields, the label of a signal defined in only one file, or the first
+	// differing samples. A missing signal or sample is empty.
+	A, B string
+	// Sample is the index of the first differing sample of the signal across
+	// the data records, and Time its offset from the start of the recording.
+	// They are only set for samples.
+	Sample int
+	Time   time.Duration
+}
+
+func (d Difference) String() string {
+	field := d.Field
+	if d.Signal >= 0 {
+		field = fmt.Sprintf("%s of signal %d", d.Field, d.Signal)
+	}
+	if d.Field == "Samples" {
+		return fmt.Sprintf("%s: sample %d at %v: %q != %q", field, d.Sample, d.Time, d.A, d.B)
+	}
+	return fmt.Sprintf("%s: %q != %q", field, d.A, d.B)
+}
+
+// Equal returns whether a and b have the same header and the same samples.
+func Equal(a, b *Edf) (bool, error) {
+	differences, err := Diff(a, b)
+	return len(differences) == 0, err
+}
+
+// Diff returns the differences between a and b: the header fields, as they
+// would be written, that differ, the signals defined in only one file, then for
+// each signal defined in both files the first sample that differs. Both headers
+// must be writable.
+func Diff(a, b *Edf) ([]Difference, error) {
+	differences, err := diffHeaders(a.Header, b.Header)
+	if err != nil {
+		return nil, err
+	}
+	numSignals := len(a.Header.Signals)
+	if len(b.Header.Signals) < numSignals {
+		numSignals = len(b.Header.Signals)
+	}
+	for s := 0; s < numSignals; s++ {
+		if d, ok := diffSamples(a, b, s); ok {
+			differences = append(differences, d)
+		}
+	}
+	return differences, nil
+}
+
+// Returns the header fields, as they would be written, that differ between a
+// and b, for the file and the signals defined in both, followed by the signals
+// defined in only one of them.
+func diffHeaders(a, b *Header) ([]Difference, error) {
+	rawA, err := encodeRawHeader(a)
+	if err != nil {
+		return nil, err
+	}
+	rawB, err := encodeRawHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var differences []Difference
+	for _, f := range headerFields {
+		if rawA.fields[f.name] != rawB.fields[f.name] {
+			differences = append(differences, Difference{Field: f.name, Signal: -1,
+				A: strings.TrimSpace(rawA.fields[f.name]), B: strings.TrimSpace(rawB.fields[f.name])})
+		}
+	}
+	numSignals := len(rawA.signals)
+	if len(rawB.signals) < numSignals {
+		numSignals = len(rawB.signals)
+	}
+	for s := 0; s < numSignals; s++ {
+		for _, f := range signalFields {
+			if rawA.signals[s][f.name] != rawB.signals[s][f.name] {
+				differences = append(differences, Difference{Field: f.name, Signal: s,
+					A: strings.TrimSpace(rawA.signals[s][f.name]), B: strings.TrimSpace(rawB.signals[s][f.name])})
+			}
+		}
+	}
+	for s := numSignals; s < len(rawA.signals); s++ {
+		differences = append(differences, Difference{Field: "Signals", Signal: s, A: strings.TrimSpace(rawA.signals[s]["Label"])})
+	}
+	for s := numSignals; s < len(rawB.signals); s++ {
+		differences = append(differences, Difference{Field: "Signals", Signal: s, B: strings.TrimSpace(rawB.signals[s]["Label"])})
+	}
+	return differences, nil
+}
+
+// Returns the fields of the header as they would be written.
+func encodeRawHeader(header *Header) (*rawHeader, error) {
+	output := new(bytes.Buffer)
+	if err := writeHeader(output, header); err != nil {
+		return nil, err
+	}
+	return readRawHeader(output, nil)
+}
+
+// Returns the first sample of the signal differing between a and b, if any.
+func diffSamples(a, b *Edf, signal int) (Difference, bool) {
+	samplesA, samplesB := a.Samples(signal), b.Samples(signal)
+	i := 0
+	for i < len(samplesA) && i < len(samplesB) && samplesA[i] == samplesB[i] {
+		i++
+	}
+	if i == len(samplesA) && i == len(samplesB) {
+		return Difference{}, false
+	}
+	d := Difference{Field: "Samples", Signal: signal, Sample: i}
+	if i < len(samplesA) {
+		d.A = strconv.Itoa(int(samplesA[i]))
+	}
+	if i < len(samplesB) {
+		d.B = strconv.Itoa(int(samplesB[i]))
+	}
+	e := a
+	if i >= len(samplesA) {
+		e = b
+	}
+	d.Time = sampleTime(e, signal, i)
+	return d, true
+}
+
+// Returns the offset from the start of the recording of the index-th sample of
+// the signal across the data records.
+func sampleTime(e *Edf, signal int, index int) time.Duration {
+	n := int(e.Header.Signals[signal].SamplesRecord)
+	record := index / n
+	onset, err := e.Header.RecordOnset(record, &e.Records[record])
+	if err != nil {
+		onset = float64(record) * float64(e.Header.DurationDataRecords)
+	}
+	seconds := onset + float64(index%n)*float64(e.Header.DurationDataRecords)/float64(n)
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDiff(t *testing.T) {
+	data, _ := testFile(3)
+	a, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equal, err := Equal(a, b); err != nil || !equal {
+		t.Errorf("Files should be equal (error %v)", err)
+	}
+
+	b.Header.StartTime = "10.20.31"
+	b.Header.Signals[1].Label = "Thorax"
+	b.Header.Signals[1].PhysMax = 41
+	b.Records[1].Signals[0].Samples[1]++
+	b.Records = b.Records[:2]
+	b.Header.NumDataRecords = 2
+
+	differences, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Difference{
+		{Field: "StartTime", Signal: -1, A: "10.20.30", B: "10.20.31"},
+		{Field: "NumDataRecords", Signal: -1, A: "3", B: "2"},
+		{Field: "Label", Signal: 1, A: "Resp", B: "Thorax"},
+		{Field: "PhysicalMaximum", Signal: 1, A: "40", B: "41"},
+		{Field: "Samples", Signal: 0, A: sample(a, 1, 0, 1), B: sample(b, 1, 0, 1), Sample: 5, Time: 1250 * time.Millisecond},
+		{Field: "Samples", Signal: 1, A: sample(a, 2, 1, 0), Sample: 4, Time: 2 * time.Second},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("%v should be equal to %v", differences, expected)
+	}
+	if s := differences[5].String(); s != `Samples of signal 1: sample 4 at 2s: "`+sample(a, 2, 1, 0)+`" != ""` {
+		t.Errorf("Unexpected description %s", s)
+	}
+}
+
+// Returns the formatted i-th sample of a signal in a data record of e.
+func sample(e *Edf, record, signal, i int) string {
+	return strconv.Itoa(int(e.Records[record].Signals[signal].Samples[i]))
+}
+
+func TestDiffSignals(t *testing.T) {
+	data, _ := testFile(1)
+	a, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Header.Signals = b.Header.Signals[:1]
+	b.Header.NumSignals = 1
+	for i := range b.Records {
+		b.Records[i].Signals = b.Records[i].Signals[:1]
+	}
+
+	differences, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Difference{
+		{Field: "HeaderSize", Signal: -1, A: "768", B: "512"},
+		{Field: "NumSignals", Signal: -1, A: "2", B: "1"},
+		{Field: "Signals", Signal: 1, A: "Resp"},
+	}
+	if !reflect.DeepEqual(differences, expected) {
+		t.Errorf("%v should be equal to %v", differences, expected)
+	}
+	if differences, err = Diff(b, a); err != nil || differences[2].B != "Resp" {
+		t.Errorf("Unexpected differences %v (error %v)", differences, err)
+	}
+}
@@ -21,8 +21,22 @@ type Edf struct {
 
 	// Records
 	Records []Record
+
+	// Samples of each signal across all records, when they are stored
+	// contiguously, and the records viewing them. See Samples.
+	columns       [][]int32
+	columnRecords []Record
 }
 
+// BDFVersion is the version field of BioSemi BDF and BDF+ files, whose samples
+// are 24-bit integers instead of the 16-bit integers of EDF files.
+const BDFVersion = "\xffBIOSEMI"
+
+// UnknownNumDataRecords is the value of Header.NumDataRecords when the number
+// of data records is not known, which the EDF specification represents as -1
+// while the file is being recorded.
+const UnknownNumDataRecords = ^uint32(0)
+
 // Header represents an EDF+ header.
 type Header struct {
 	Version             string
@@ -50,6 +64,32 @@ type SignalDefinition struct {
 	Prefiltering      string
 	SamplesRecord     uint32
 	Reserved          string
+
+	// Parsed values of PhysicalMinimum, PhysicalMaximum, DigitalMinimum and
+	// DigitalMaximum. They take precedence when writing: the raw strings are
+	// only kept if they represent these values, and are formatted from them
+	// otherwise.
+	PhysMin float64
+	PhysMax float64
+	DigiMin int32
+	DigiMax int32
+}
+
+// Gain returns the factor converting digital sample values to physical units.
+// It is infinite or NaN for a degenerate digital range, which only lenient
+// decoding accepts.
+func (s *SignalDefinition) Gain() float64 {
+	return (s.PhysMax - s.PhysMin) / float64(s.DigiMax-s.DigiMin)
+}
+
+// Offset returns the physical value of the digital value 0.
+func (s *SignalDefinition) Offset() float64 {
+	return s.PhysMin - s.Gain()*float64(s.DigiMin)
+}
+
+// IsAnnotation returns whether the signal is an EDF+ or BDF+ annotation signal.
+func (s *SignalDefinition) IsAnnotation() bool {
+	return s.Label == "EDF Annotations" || s.Label == "BDF Annotations"
 }
 
 // Record holds a single record entry from the EDF file.
@@ -58,6 +98,39 @@ type Record struct {
 }
 
 // SignalRecord holds the samples for a single signal inside a data record.
+// Samples are wide enough for both EDF (16-bit) and BDF (24-bit) files.
 type SignalRecord struct {
-	Samples []int16
+	Samples []int32
+}
+
+// IsBDF returns whether the header is the one of a BDF or BDF+ file.
+func (h *Header) IsBDF() bool {
+	return h.Version == BDFVersion
+}
+
+// SampleSize returns the size in bytes of a single sample: 3 for BDF files and
+// 2 for EDF files.
+func (h *Header) SampleSize() int {
+	if h.IsBDF() {
+		return 3
+	}
+	return 2
+}
+
+// RecordSize returns the size in bytes of a single data record.
+func (h *Header) RecordSize() int64 {
+	var samples int64
+	for _, s := range h.Signals {
+		samples += int64(s.SamplesRecord)
+	}
+	return int64(h.SampleSize()) * samples
+}
+
+// DataSize returns the expected size in bytes of the data records following
+// the header, or -1 if the number of data records is unknown.
+func (h *Header) DataSize() int64 {
+	if h.NumDataRecords == UnknownNumDataRecords {
+		return -1
+	}
+	return int64(h.NumDataRecords) * h.RecordSize()
 }
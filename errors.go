@@ -0,0 +1,98 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import "fmt"
+
+// ParseError is returned when an EDF file cannot be read. It locates the
+// failure in the file.
+type ParseError struct {
+	// Field is the name of the Header or SignalDefinition field that could not
+	// be read, or "Records" for data records.
+	Field string
+	// Signal is the index of the signal definition holding the field, or -1.
+	Signal int
+	// Record is the index of the data record that could not be read, or -1.
+	Record int
+	// Offset is the position in bytes of the field or data record in the file.
+	Offset int64
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	switch {
+	case e.Record >= 0:
+		return fmt.Sprintf("Data record %d at offset %d: %v", e.Record, e.Offset, e.Err)
+	case e.Signal >= 0:
+		return fmt.Sprintf("%s of signal %d at offset %d: %v", e.Field, e.Signal, e.Offset, e.Err)
+	default:
+		return fmt.Sprintf("%s at offset %d: %v", e.Field, e.Offset, e.Err)
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Returns a ParseError for a header field of a header with numSignals signals.
+func fieldError(field string, signal int, numSignals int, err error) *ParseError {
+	offset := 0
+	if signal < 0 {
+		for _, f := range headerFields {
+			if f.name == field {
+				break
+			}
+			offset += f.width
+		}
+	} else {
+		offset = 256
+		for _, f := range signalFields {
+			if f.name == field {
+				offset += signal * f.width
+				break
+			}
+			offset += numSignals * f.width
+		}
+	}
+	return &ParseError{Field: field, Signal: signal, Record: -1, Offset: int64(offset), Err: err}
+}
+
+// Returns a ParseError for the failure to read the header field found at the
+// given offset, in a header with numSignals signals.
+func headerReadError(offset int, numSignals int, err error) *ParseError {
+	position := 0
+	for _, f := range headerFields {
+		if offset < position+f.width {
+			return &ParseError{Field: f.name, Signal: -1, Record: -1, Offset: int64(offset), Err: err}
+		}
+		position += f.width
+	}
+	for _, f := range signalFields {
+		if offset < position+numSignals*f.width {
+			signal := (offset - position) / f.width
+			return &ParseError{Field: f.name, Signal: signal, Record: -1, Offset: int64(offset), Err: err}
+		}
+		position += numSignals * f.width
+	}
+	return &ParseError{Field: "HeaderSize", Signal: -1, Record: -1, Offset: int64(offset), Err: err}
+}
+
+// Returns a ParseError for the failure to read the index-th data record.
+func recordError(header *Header, index int, err error) *ParseError {
+	offset := int64(header.HeaderSize) + int64(index)*header.RecordSize()
+	return &ParseError{Field: "Records", Signal: -1, Record: index, Offset: offset, Err: err}
+}
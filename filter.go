@@ -0,0 +1,63 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+// A SignalFilter selects signals from their index and definition.
+type SignalFilter func(index int, definition *SignalDefinition) bool
+
+// SelectLabels returns a filter selecting the signals with the given labels.
+func SelectLabels(labels ...string) SignalFilter {
+	selected := map[string]bool{}
+	for _, label := range labels {
+		selected[label] = true
+	}
+	return func(index int, definition *SignalDefinition) bool {
+		return selected[definition.Label]
+	}
+}
+
+// SelectIndices returns a filter selecting the signals with the given indices.
+func SelectIndices(indices ...int) SignalFilter {
+	selected := map[int]bool{}
+	for _, index := range indices {
+		selected[index] = true
+	}
+	return func(index int, definition *SignalDefinition) bool {
+		return selected[index]
+	}
+}
+
+// Returns the indices of the signals of the header selected by filter.
+func selectSignals(header *Header, filter SignalFilter) []int {
+	signals := []int{}
+	for i := range header.Signals {
+		if filter(i, &header.Signals[i]) {
+			signals = append(signals, i)
+		}
+	}
+	return signals
+}
+
+// Returns a copy of the header only defining the given signals.
+func (h *Header) withSignals(signals []int) *Header {
+	header := *h
+	header.Signals = make([]SignalDefinition, len(signals))
+	for i, s := range signals {
+		header.Signals[i] = h.Signals[s]
+	}
+	header.NumSignals = uint32(len(signals))
+	header.HeaderSize = uint32(256 * (len(signals) + 1))
+	return &header
+}
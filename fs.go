@@ -0,0 +1,39 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import "io/fs"
+
+// ReadFS reads the EDF file with the given name from fsys. It allows reading
+// files from any file system, such as zip archives opened with archive/zip,
+// without extracting them first:
+//
+//	archive, err := zip.OpenReader("sleep-edf.zip")
+//	...
+//	names, err := fs.Glob(archive, "*/*.edf")
+//	...
+//	for _, name := range names {
+//		e, err := edf.ReadFS(archive, name)
+//		...
+//	}
+func ReadFS(fsys fs.FS, name string) (*Edf, error) {
+	fileInput, err := fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer fileInput.Close()
+
+	return Read(fileInput)
+}
@@ -0,0 +1,74 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestReadFS(t *testing.T) {
+	data, records := testFile(2)
+	archive := new(bytes.Buffer)
+	w := zip.NewWriter(archive)
+	for _, name := range []string{"sleep/SC4001E0-PSG.edf", "sleep/SC4002E0-PSG.edf", "README"} {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := fs.Glob(r, "*/*.edf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Errorf("Unexpected files %v", names)
+	}
+	for _, name := range names {
+		e, err := ReadFS(r, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Records, records) {
+			t.Errorf("%v should be equal to %v", e.Records, records)
+		}
+	}
+	// The size of the files is known, so that their headers are checked
+	// against it.
+	f, err := r.Open(names[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if size := inputSize(f); size != int64(len(data)) {
+		t.Errorf("Input size is %d, should be %d", size, len(data))
+	}
+
+	if _, err := ReadFS(r, "sleep/missing.edf"); err == nil {
+		t.Error("Reading a missing file should fail")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Limits keeping the memory used by fuzzing small.
+var fuzzLimits = Limits{MaxSignals: 64, MaxSamplesRecord: 1024, MaxBytes: 1 << 20}
+
+// Adds valid files, and files with an unknown number of data records, to the
+// corpus of f.
+func addFuzzCorpus(f *testing.F) {
+	for _, n := range []int{0, 1, 3} {
+		data, _ := testFile(n)
+		f.Add(data)
+		copy(data[numDataRecordsOffset:], pad("-1", 8))
+		f.Add(data)
+	}
+}
+
+func FuzzReadHeader(f *testing.F) {
+	addFuzzCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		header, err := readHeader(bytes.NewReader(data), &fuzzLimits)
+		if err != nil {
+			return
+		}
+		if int(header.NumSignals) != len(header.Signals) {
+			t.Errorf("Header declares %d signals, found %d definitions", header.NumSignals, len(header.Signals))
+		}
+		if decodedRecordSize(header) > fuzzLimits.MaxBytes {
+			t.Errorf("Record size %d exceeds the limits", decodedRecordSize(header))
+		}
+	})
+}
+
+func FuzzReadRecords(f *testing.F) {
+	addFuzzCorpus(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, lenient := range []bool{false, true} {
+			d := NewDecoder(bytes.NewReader(data))
+			d.Lenient = lenient
+			d.Limits = &fuzzLimits
+			e, err := d.Decode()
+			if err != nil {
+				continue
+			}
+			if int(e.Header.NumDataRecords) != len(e.Records) {
+				t.Errorf("Header declares %d data records, found %d", e.Header.NumDataRecords, len(e.Records))
+			}
+			for s := range e.Header.Signals {
+				e.Samples(s)
+			}
+		}
+
+		ra, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		if _, err := ra.ReadAll(); err != nil {
+			t.Errorf("Reading the records of a valid header failed with %v", err)
+		}
+	})
+}
@@ -0,0 +1,281 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// fieldSpec describes a fixed-width ASCII field of the header.
+type fieldSpec struct {
+	name  string
+	width int
+}
+
+// Fields of the first 256 bytes of the header.
+var headerFields = []fieldSpec{
+	{"Version", 8},
+	{"PatiendID", 80},
+	{"RecordingID", 80},
+	{"StartDate", 8},
+	{"StartTime", 8},
+	{"HeaderSize", 8},
+	{"Reserved", 44},
+	{"NumDataRecords", 8},
+	{"DurationDataRecords", 8},
+	{"NumSignals", 4},
+}
+
+// Fields of the signal definitions. Each field is repeated for every signal
+// before the next field.
+var signalFields = []fieldSpec{
+	{"Label", 16},
+	{"TransducerType", 80},
+	{"PhysicalDimension", 8},
+	{"PhysicalMinimum", 8},
+	{"PhysicalMaximum", 8},
+	{"DigitalMinimum", 8},
+	{"DigitalMaximum", 8},
+	{"Prefiltering", 80},
+	{"SamplesRecord", 8},
+	{"Reserved", 32},
+}
+
+// rawHeader holds the contents of the header fields, before any parsing.
+type rawHeader struct {
+	fields  map[string]string
+	signals []map[string]string
+}
+
+// Reads the header of the EDF+ file, checking it against limits unless nil.
+func readHeader(input io.Reader, limits *Limits) (*Header, error) {
+	raw, err := readRawHeader(input, limits)
+	if err != nil {
+		return nil, err
+	}
+	p := &headerParser{}
+	header := p.parse(raw)
+	if len(p.errs) > 0 {
+		return nil, p.errs[0]
+	}
+	if err := checkHeaderSize(header); err != nil {
+		return nil, err
+	}
+	if err := limits.checkRecordSize(header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+// Returns an error if the header size does not match the number of signals.
+// Data records are located from the actual size of the header, which must then
+// be the declared one for all readers to agree.
+func checkHeaderSize(header *Header) error {
+	if header.HeaderSize != 256*(header.NumSignals+1) {
+		return fieldError("HeaderSize", -1, len(header.Signals),
+			fmt.Errorf("Header size %d does not match the %d signals of the file", header.HeaderSize, header.NumSignals))
+	}
+	return nil
+}
+
+// Reads the fields of the header. Only the number of signals is parsed, as it
+// determines the size of the header, and checked against limits unless nil.
+func readRawHeader(input io.Reader, limits *Limits) (*rawHeader, error) {
+	data := make([]byte, 256)
+	if n, err := io.ReadFull(input, data); err != nil {
+		return nil, headerReadError(n, 0, err)
+	}
+	raw := &rawHeader{fields: map[string]string{}}
+	for _, f := range headerFields {
+		raw.fields[f.name] = string(data[:f.width])
+		data = data[f.width:]
+	}
+
+	numSignals, err := strconv.ParseUint(strings.TrimSpace(raw.fields["NumSignals"]), 10, 32)
+	if err != nil {
+		return nil, fieldError("NumSignals", -1, 0, err)
+	}
+	if err := limits.checkNumSignals(numSignals); err != nil {
+		return nil, err
+	}
+	data = make([]byte, 256*numSignals)
+	if n, err := io.ReadFull(input, data); err != nil {
+		return nil, headerReadError(256+n, int(numSignals), err)
+	}
+	raw.signals = make([]map[string]string, numSignals)
+	for i := range raw.signals {
+		raw.signals[i] = map[string]string{}
+	}
+	for _, f := range signalFields {
+		for i := range raw.signals {
+			raw.signals[i][f.name] = string(data[:f.width])
+			data = data[f.width:]
+		}
+	}
+	return raw, nil
+}
+
+// headerParser converts the fields of a raw header, collecting all the errors.
+// In lenient mode, recoverable quirks of the fields are normalized and reported
+// as warnings.
+type headerParser struct {
+	lenient    bool
+	numSignals int
+	errs       []*ParseError
+	warnings   []Problem
+}
+
+// Trims a field, including NUL bytes in lenient mode.
+func (p *headerParser) text(field string, signal int, value string) string {
+	trimmed := strings.TrimSpace(value)
+	if !p.lenient {
+		return trimmed
+	}
+	if normalized := strings.Trim(value, " \x00"); normalized != trimmed {
+		p.warnings = append(p.warnings, Problem{field, signal, SeverityWarning, "Field padded with NUL bytes"})
+		return normalized
+	}
+	return trimmed
+}
+
+// Trims a numeric field, accepting a comma as decimal separator in lenient
+// mode.
+func (p *headerParser) number(field string, signal int, value string) string {
+	value = p.text(field, signal, value)
+	if p.lenient && strings.Count(value, ",") == 1 && !strings.Contains(value, ".") {
+		p.warnings = append(p.warnings, Problem{field, signal, SeverityWarning, "Comma used as decimal separator in " + value})
+		return strings.Replace(value, ",", ".", 1)
+	}
+	return value
+}
+
+func (p *headerParser) uint(field string, signal int, value string) uint32 {
+	i, err := strconv.ParseUint(p.text(field, signal, value), 10, 32)
+	if err != nil {
+		p.errs = append(p.errs, fieldError(field, signal, p.numSignals, err))
+	}
+	return uint32(i)
+}
+
+func (p *headerParser) float(field string, signal int, value string) float32 {
+	f, err := strconv.ParseFloat(p.number(field, signal, value), 32)
+	if err != nil {
+		p.errs = append(p.errs, fieldError(field, signal, p.numSignals, err))
+	}
+	return float32(f)
+}
+
+func (p *headerParser) float64(field string, signal int, value string) float64 {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		p.errs = append(p.errs, fieldError(field, signal, p.numSignals, err))
+	}
+	return f
+}
+
+func (p *headerParser) int(field string, signal int, value string) int32 {
+	i, err := parseDigital(value)
+	if err != nil {
+		p.errs = append(p.errs, fieldError(field, signal, p.numSignals, err))
+	}
+	return i
+}
+
+// Parses a digital minimum or maximum. Integral decimal values, such as
+// 2047.0, are accepted as some writers produce them.
+func parseDigital(value string) (int32, error) {
+	i, err := strconv.ParseInt(value, 10, 32)
+	if err == nil {
+		return int32(i), nil
+	}
+	if f, ferr := strconv.ParseFloat(value, 64); ferr == nil && f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+		return int32(f), nil
+	}
+	return 0, err
+}
+
+// Parses the fields of the header. Fields that cannot be parsed are left
+// empty, and the errors collected.
+func (p *headerParser) parse(raw *rawHeader) *Header {
+	p.numSignals = len(raw.signals)
+	field := func(name string) string {
+		return p.text(name, -1, raw.fields[name])
+	}
+	header := &Header{
+		Version:        field("Version"),
+		PatiendID:      field("PatiendID"),
+		RecordingID:    field("RecordingID"),
+		StartDate:      field("StartDate"),
+		StartTime:      field("StartTime"),
+		HeaderSize:     p.uint("HeaderSize", -1, raw.fields["HeaderSize"]),
+		Reserved:       field("Reserved"),
+		NumDataRecords: UnknownNumDataRecords,
+	}
+	if strings.TrimSpace(raw.fields["NumDataRecords"]) != "-1" {
+		header.NumDataRecords = p.uint("NumDataRecords", -1, raw.fields["NumDataRecords"])
+	}
+	header.DurationDataRecords = p.float("DurationDataRecords", -1, raw.fields["DurationDataRecords"])
+	header.NumSignals = uint32(len(raw.signals))
+	header.Signals = make([]SignalDefinition, len(raw.signals))
+
+	for i, fields := range raw.signals {
+		field := func(name string) string {
+			return p.text(name, i, fields[name])
+		}
+		number := func(name string) string {
+			return p.number(name, i, fields[name])
+		}
+		header.Signals[i] = SignalDefinition{
+			Label:             field("Label"),
+			TransducerType:    field("TransducerType"),
+			PhysicalDimension: field("PhysicalDimension"),
+			PhysicalMinimum:   number("PhysicalMinimum"),
+			PhysicalMaximum:   number("PhysicalMaximum"),
+			DigitalMinimum:    number("DigitalMinimum"),
+			DigitalMaximum:    number("DigitalMaximum"),
+			Prefiltering:      field("Prefiltering"),
+			SamplesRecord:     p.uint("SamplesRecord", i, fields["SamplesRecord"]),
+			Reserved:          field("Reserved"),
+		}
+		s := &header.Signals[i]
+		s.PhysMin = p.float64("PhysicalMinimum", i, s.PhysicalMinimum)
+		s.PhysMax = p.float64("PhysicalMaximum", i, s.PhysicalMaximum)
+		errs := len(p.errs)
+		s.DigiMin = p.int("DigitalMinimum", i, s.DigitalMinimum)
+		s.DigiMax = p.int("DigitalMaximum", i, s.DigitalMaximum)
+		// A degenerate digital range would give infinite gains, and is only
+		// accepted in lenient mode.
+		if len(p.errs) == errs && s.DigiMin == s.DigiMax {
+			err := fieldError("DigitalMaximum", i, p.numSignals,
+				fmt.Errorf("Digital minimum and maximum are both %d", s.DigiMax))
+			if p.lenient {
+				p.warnings = append(p.warnings, Problem{"DigitalMaximum", i, SeverityWarning, err.Err.Error()})
+			} else {
+				p.errs = append(p.errs, err)
+			}
+		}
+	}
+	// Records without samples would be read forever.
+	if header.NumDataRecords == UnknownNumDataRecords && header.RecordSize() == 0 {
+		p.errs = append(p.errs, fieldError("NumDataRecords", -1, p.numSignals,
+			errors.New("Unknown number of data records without samples")))
+	}
+	return header
+}
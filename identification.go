@@ -0,0 +1,188 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Layout of the dates in EDF+ identification fields, such as 02-MAY-1951.
+const identificationDateLayout = "02-Jan-2006"
+
+// PatientInfo holds the subfields of an EDF+ patient identification. Unknown
+// subfields are zero values.
+type PatientInfo struct {
+	// Hospital administration code.
+	Code string
+	// Sex, either "M" or "F".
+	Sex       string
+	Birthdate time.Time
+	Name      string
+	// Additional subfields, following the ones defined by the specification.
+	Additional []string
+}
+
+// ParsePatientInfo parses an EDF+ patient identification, such as
+// "MCH-0234567 F 02-MAY-1951 Haagse_Harry".
+func ParsePatientInfo(field string) (*PatientInfo, error) {
+	subfields := strings.Fields(field)
+	if len(subfields) < 4 {
+		return nil, fmt.Errorf("Patient identification %q has less than 4 subfields", field)
+	}
+	info := &PatientInfo{
+		Code:       parseSubfield(subfields[0]),
+		Name:       parseSubfield(subfields[3]),
+		Additional: parseSubfields(subfields[4:]),
+	}
+	switch subfields[1] {
+	case "M", "F":
+		info.Sex = subfields[1]
+	case "X":
+	default:
+		return nil, fmt.Errorf("Invalid sex %q in patient identification", subfields[1])
+	}
+	var err error
+	if info.Birthdate, err = parseIdentificationDate(subfields[2]); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// PatientInfo parses the patient identification of an EDF+ header.
+func (h *Header) PatientInfo() (*PatientInfo, error) {
+	return ParsePatientInfo(h.PatiendID)
+}
+
+// String formats the patient identification as an EDF+ header field.
+func (p *PatientInfo) String() string {
+	subfields := []string{formatSubfield(p.Code), formatSubfield(p.Sex), formatIdentificationDate(p.Birthdate), formatSubfield(p.Name)}
+	for _, s := range p.Additional {
+		subfields = append(subfields, formatSubfield(s))
+	}
+	return strings.Join(subfields, " ")
+}
+
+// RecordingInfo holds the subfields of an EDF+ recording identification.
+// Unknown subfields are zero values.
+type RecordingInfo struct {
+	// Start date of the recording, with a 4-digit year.
+	StartDate time.Time
+	// Hospital administration code of the investigation.
+	AdminCode  string
+	Technician string
+	Equipment  string
+	// Additional subfields, following the ones defined by the specification.
+	Additional []string
+}
+
+// ParseRecordingInfo parses an EDF+ recording identification, such as
+// "Startdate 02-MAR-2002 PSG-1234/2002 NN Telemetry03".
+func ParseRecordingInfo(field string) (*RecordingInfo, error) {
+	subfields := strings.Fields(field)
+	if len(subfields) < 5 || subfields[0] != "Startdate" {
+		return nil, fmt.Errorf("Recording identification %q does not start with Startdate and 4 subfields", field)
+	}
+	info := &RecordingInfo{
+		AdminCode:  parseSubfield(subfields[2]),
+		Technician: parseSubfield(subfields[3]),
+		Equipment:  parseSubfield(subfields[4]),
+		Additional: parseSubfields(subfields[5:]),
+	}
+	var err error
+	if info.StartDate, err = parseIdentificationDate(subfields[1]); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// RecordingInfo parses the recording identification of an EDF+ header.
+func (h *Header) RecordingInfo() (*RecordingInfo, error) {
+	return ParseRecordingInfo(h.RecordingID)
+}
+
+// String formats the recording identification as an EDF+ header field.
+func (r *RecordingInfo) String() string {
+	subfields := []string{"Startdate", formatIdentificationDate(r.StartDate), formatSubfield(r.AdminCode), formatSubfield(r.Technician), formatSubfield(r.Equipment)}
+	for _, s := range r.Additional {
+		subfields = append(subfields, formatSubfield(s))
+	}
+	return strings.Join(subfields, " ")
+}
+
+// Start returns the start date and time of the recording. The 2-digit year of
+// the StartDate field is disambiguated with the 4-digit year of the EDF+
+// recording identification when available, and otherwise interpreted as in
+// 1985-2084 per the EDF specification.
+func (h *Header) Start() (time.Time, error) {
+	t, err := time.Parse("02.01.06 15.04.05", h.StartDate+" "+h.StartTime)
+	if err != nil {
+		return t, err
+	}
+	year := t.Year() % 100
+	if info, err := h.RecordingInfo(); err == nil && !info.StartDate.IsZero() {
+		year = info.StartDate.Year()
+	} else if year >= 85 {
+		year += 1900
+	} else {
+		year += 2000
+	}
+	return time.Date(year, t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+}
+
+// Decodes an EDF+ identification subfield, where X stands for unknown and
+// underscores for spaces.
+func parseSubfield(s string) string {
+	if s == "X" {
+		return ""
+	}
+	return strings.Replace(s, "_", " ", -1)
+}
+
+func parseSubfields(subfields []string) []string {
+	var result []string
+	for _, s := range subfields {
+		result = append(result, parseSubfield(s))
+	}
+	return result
+}
+
+// Encodes an EDF+ identification subfield.
+func formatSubfield(s string) string {
+	if s == "" {
+		return "X"
+	}
+	return strings.Replace(s, " ", "_", -1)
+}
+
+func parseIdentificationDate(s string) (time.Time, error) {
+	if s == "X" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(identificationDateLayout, s)
+	if err != nil {
+		return time.Time{}, errors.New("Invalid date " + s + ", expected dd-MMM-yyyy")
+	}
+	return date, nil
+}
+
+func formatIdentificationDate(date time.Time) string {
+	if date.IsZero() {
+		return "X"
+	}
+	return strings.ToUpper(date.Format(identificationDateLayout))
+}
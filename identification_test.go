@@ -0,0 +1,91 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPatientInfo(t *testing.T) {
+	for _, test := range []struct {
+		field    string
+		expected PatientInfo
+	}{
+		{"MCH-0234567 F 02-MAY-1951 Haagse_Harry", PatientInfo{Code: "MCH-0234567", Sex: "F", Birthdate: time.Date(1951, 5, 2, 0, 0, 0, 0, time.UTC), Name: "Haagse Harry"}},
+		{"X X X X", PatientInfo{}},
+		{"X M X X Some_notes", PatientInfo{Sex: "M", Additional: []string{"Some notes"}}},
+	} {
+		info, err := ParsePatientInfo(test.field)
+		if err != nil {
+			t.Errorf("Parsing %q: %v", test.field, err)
+			continue
+		}
+		if !reflect.DeepEqual(*info, test.expected) {
+			t.Errorf("%+v should be equal to %+v", *info, test.expected)
+		}
+		if info.String() != test.field {
+			t.Errorf("%q should be equal to %q", info.String(), test.field)
+		}
+	}
+
+	for _, field := range []string{"", "Haagse Harry", "X Y X X", "X F 1951-05-02 X"} {
+		if _, err := ParsePatientInfo(field); err == nil {
+			t.Errorf("Parsing %q should fail", field)
+		}
+	}
+}
+
+func TestRecordingInfo(t *testing.T) {
+	field := "Startdate 02-MAR-2002 PSG-1234/2002 NN Telemetry03"
+	info, err := ParseRecordingInfo(field)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RecordingInfo{StartDate: time.Date(2002, 3, 2, 0, 0, 0, 0, time.UTC), AdminCode: "PSG-1234/2002", Technician: "NN", Equipment: "Telemetry03"}
+	if !reflect.DeepEqual(*info, expected) {
+		t.Errorf("%+v should be equal to %+v", *info, expected)
+	}
+	if info.String() != field {
+		t.Errorf("%q should be equal to %q", info.String(), field)
+	}
+	if _, err := ParseRecordingInfo("PSG-1234/2002 NN Telemetry03"); err == nil {
+		t.Error("Parsing a recording identification without start date should fail")
+	}
+}
+
+func TestStart(t *testing.T) {
+	for _, test := range []struct {
+		recordingID string
+		startDate   string
+		expected    time.Time
+	}{
+		{"", "02.03.02", time.Date(2002, 3, 2, 10, 20, 30, 0, time.UTC)},
+		{"", "02.03.84", time.Date(2084, 3, 2, 10, 20, 30, 0, time.UTC)},
+		{"", "02.03.85", time.Date(1985, 3, 2, 10, 20, 30, 0, time.UTC)},
+		{"Startdate 02-MAR-1975 X X X", "02.03.75", time.Date(1975, 3, 2, 10, 20, 30, 0, time.UTC)},
+		{"Startdate 02-MAR-2090 X X X", "02.03.90", time.Date(2090, 3, 2, 10, 20, 30, 0, time.UTC)},
+		{"Startdate X X X X", "02.03.90", time.Date(1990, 3, 2, 10, 20, 30, 0, time.UTC)},
+	} {
+		h := &Header{RecordingID: test.recordingID, StartDate: test.startDate, StartTime: "10.20.30"}
+		start, err := h.Start()
+		if err != nil {
+			t.Error(err)
+		} else if !start.Equal(test.expected) {
+			t.Errorf("%v should be equal to %v", start, test.expected)
+		}
+	}
+}
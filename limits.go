@@ -0,0 +1,146 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// Estimated number of bytes allocated to decode a data record, besides its
+// signals, for each of its signals, besides their samples, and for each sample:
+// the slice headers of Record.Signals and SignalRecord.Samples on 64-bit
+// platforms, and the int32 samples.
+const (
+	decodedRecordBytes = 24
+	decodedSignalBytes = 24
+	decodedSampleBytes = 4
+)
+
+// Limits bounds the memory allocated when reading an EDF file, so that a
+// corrupt or hostile header cannot request more than the reader is willing to
+// allocate. A zero field disables the corresponding limit.
+type Limits struct {
+	// MaxSignals is the maximum number of signals of the file.
+	MaxSignals int
+
+	// MaxSamplesRecord is the maximum number of samples of a signal in a data
+	// record.
+	MaxSamplesRecord uint32
+
+	// MaxBytes is the maximum number of bytes allocated for the decoded data
+	// records held at once: a single data record for RecordReader, all of them
+	// for Decoder.
+	MaxBytes int64
+}
+
+// DefaultLimits are the limits used by NewRecordReader, and by Decoder unless
+// its Limits are set. Decoder ignores their MaxBytes when the size of the input
+// is known, so that files of any size can be read.
+var DefaultLimits = Limits{
+	MaxSignals:       4096,
+	MaxSamplesRecord: 1 << 20,
+	MaxBytes:         1 << 28,
+}
+
+// Returns DefaultLimits without MaxBytes, for inputs of known size that bound
+// the data records already.
+func sizedDefaultLimits() *Limits {
+	limits := DefaultLimits
+	limits.MaxBytes = 0
+	return &limits
+}
+
+// Checks the number of signals of a header, before reading their definitions.
+func (l *Limits) checkNumSignals(numSignals uint64) error {
+	if l != nil && l.MaxSignals > 0 && numSignals > uint64(l.MaxSignals) {
+		return fieldError("NumSignals", -1, 0, fmt.Errorf("%d signals exceed the limit of %d", numSignals, l.MaxSignals))
+	}
+	return nil
+}
+
+// Checks the size of the data records of a header.
+func (l *Limits) checkRecordSize(header *Header) error {
+	if l == nil {
+		return nil
+	}
+	size := int64(decodedRecordBytes)
+	for s := range header.Signals {
+		samples := header.Signals[s].SamplesRecord
+		if l.MaxSamplesRecord > 0 && samples > l.MaxSamplesRecord {
+			return fieldError("SamplesRecord", s, len(header.Signals),
+				fmt.Errorf("%d samples per record exceed the limit of %d", samples, l.MaxSamplesRecord))
+		}
+		size += decodedSignalSize(samples)
+		if l.MaxBytes > 0 && size > l.MaxBytes {
+			return fieldError("SamplesRecord", s, len(header.Signals),
+				fmt.Errorf("Data records of at least %d bytes exceed the limit of %d", size, l.MaxBytes))
+		}
+	}
+	return nil
+}
+
+// Checks the size of all the data records of a header, once decoded.
+func (l *Limits) checkDataSize(header *Header) error {
+	if l == nil || l.MaxBytes <= 0 || header.NumDataRecords == UnknownNumDataRecords {
+		return nil
+	}
+	if size := int64(header.NumDataRecords) * decodedRecordSize(header); size > l.MaxBytes {
+		return fieldError("NumDataRecords", -1, len(header.Signals),
+			fmt.Errorf("%d bytes of decoded data records exceed the limit of %d", size, l.MaxBytes))
+	}
+	return nil
+}
+
+// Returns the number of bytes allocated to decode a data record of header.
+func decodedRecordSize(header *Header) int64 {
+	size := int64(decodedRecordBytes)
+	for _, s := range header.Signals {
+		size += decodedSignalSize(s.SamplesRecord)
+	}
+	return size
+}
+
+// Returns the number of bytes allocated to decode the given number of samples
+// of a signal in a data record.
+func decodedSignalSize(samples uint32) int64 {
+	return decodedSignalBytes + int64(samples)*decodedSampleBytes
+}
+
+// Returns the number of bytes left to read from r, or -1 if it cannot be known
+// without reading them. For files that cannot seek, such as the ones of an
+// fs.FS, the size of the whole file is an upper bound.
+func inputSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Len() int }:
+		return int64(r.Len())
+	case interface{ Stat() (fs.FileInfo, error) }:
+		info, err := r.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		size := info.Size()
+		if seeker, ok := r.(io.Seeker); ok {
+			offset, err := seeker.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return -1
+			}
+			size -= offset
+		}
+		return size
+	}
+	return -1
+}
@@ -0,0 +1,185 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func TestLimits(t *testing.T) {
+	data, _ := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordSize := decodedRecordSize(e.Header)
+	tests := []struct {
+		limits Limits
+		field  string
+		signal int
+	}{
+		{Limits{MaxSignals: 1}, "NumSignals", -1},
+		{Limits{MaxSamplesRecord: 3}, "SamplesRecord", 0},
+		{Limits{MaxBytes: 10}, "SamplesRecord", 0},
+		{Limits{MaxBytes: 2 * recordSize}, "NumDataRecords", -1},
+	}
+	for _, test := range tests {
+		// The size of the input is unknown, so that all the limits apply.
+		d := NewDecoder(io.MultiReader(bytes.NewReader(data)))
+		d.Limits = &test.limits
+		_, err := d.Decode()
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Field != test.field || pe.Signal != test.signal {
+			t.Errorf("Decoding with limits %+v failed with %v, expected an error on %s of signal %d", test.limits, err, test.field, test.signal)
+		}
+	}
+
+	d := NewDecoder(io.MultiReader(bytes.NewReader(data)))
+	d.Limits = &Limits{MaxBytes: 3 * recordSize}
+	if _, err := d.Decode(); err != nil {
+		t.Errorf("Decoding within the limits failed with %v", err)
+	}
+
+	// Limits apply to inputs of known size too, except for the default
+	// MaxBytes.
+	d = NewDecoder(bytes.NewReader(data))
+	d.Limits = &Limits{MaxBytes: 2 * recordSize}
+	if _, err := d.Decode(); err == nil {
+		t.Error("Decoding an input of known size over the limits should fail")
+	}
+	defaults := DefaultLimits
+	defer func() { DefaultLimits = defaults }()
+	DefaultLimits.MaxBytes = 2 * recordSize
+	if _, err := Read(bytes.NewReader(data)); err != nil {
+		t.Errorf("Decoding an input of known size failed with %v", err)
+	}
+	if _, err := Read(io.MultiReader(bytes.NewReader(data))); err == nil {
+		t.Error("Decoding an input of unknown size over the default limits should fail")
+	}
+	DefaultLimits = defaults
+
+	// The number of data records is only known once they are read.
+	unknown := append([]byte(nil), data...)
+	copy(unknown[numDataRecordsOffset:], pad("-1", 8))
+	d = NewDecoder(io.MultiReader(bytes.NewReader(unknown)))
+	d.Limits = &Limits{MaxBytes: 2 * recordSize}
+	_, err = d.Decode()
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Field != "Records" || pe.Record != 2 {
+		t.Errorf("Decoding unknown records over the limits failed with %v", err)
+	}
+
+	many := append([]byte(nil), data...)
+	copy(many[252:], pad("5000", 4))
+	if _, err := NewRecordReader(bytes.NewReader(many)); err == nil {
+		t.Error("Reading more signals than DefaultLimits should fail")
+	}
+}
+
+func TestLimitsInputSize(t *testing.T) {
+	data, records := testFile(3)
+	copy(data[numDataRecordsOffset:], pad("99999999", 8))
+
+	// The declared records are not allocated as they do not fit in the input.
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Reading more records than the input holds should fail")
+	}
+	d := NewDecoder(bytes.NewReader(data))
+	d.Lenient = true
+	e, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 3 || len(e.Records) != len(records) {
+		t.Errorf("Read %d records, should be 3", e.Header.NumDataRecords)
+	}
+
+	// The size of the input is unknown.
+	d = NewDecoder(io.MultiReader(bytes.NewReader(data)))
+	d.Limits = &Limits{MaxBytes: 1 << 20}
+	if _, err := d.Decode(); err == nil {
+		t.Error("Reading more records than the limits should fail")
+	}
+
+	if _, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Reading more records than the input holds should fail")
+	}
+
+	// A header without signals, whose data records have no bytes.
+	empty := append([]byte(nil), data[:256]...)
+	copy(empty[184:], pad("256", 8))
+	copy(empty[252:], pad("0", 4))
+	var pe *ParseError
+	if _, err := Read(bytes.NewReader(empty)); !errors.As(err, &pe) || pe.Field != "NumDataRecords" {
+		t.Errorf("Reading data records without samples failed with %v", err)
+	}
+}
+
+func TestLimitsHugeRecords(t *testing.T) {
+	// A header declaring a data record of 200 signals of 1,000,000 samples,
+	// without any data.
+	header := &Header{Version: "0", StartDate: "02.01.17", StartTime: "10.20.30", NumDataRecords: 1, DurationDataRecords: 1, NumSignals: 200}
+	for i := 0; i < 200; i++ {
+		header.Signals = append(header.Signals, SignalDefinition{
+			Label: fmt.Sprintf("EEG %d", i), PhysMin: -1, PhysMax: 1, DigiMin: -1, DigiMax: 1, SamplesRecord: 1000000})
+	}
+	data := new(bytes.Buffer)
+	if err := writeHeader(data, header); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, input := range map[string]func() io.Reader{
+		"known size":   func() io.Reader { return bytes.NewReader(data.Bytes()) },
+		"unknown size": func() io.Reader { return io.MultiReader(bytes.NewReader(data.Bytes())) },
+	} {
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		_, err := Read(input())
+		runtime.ReadMemStats(&after)
+		if err == nil {
+			t.Errorf("%s: Reading huge data records should fail", name)
+		}
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+			t.Errorf("%s: Reading huge data records allocated %d bytes", name, allocated)
+		}
+	}
+
+	// The data record is not read, and thus not allocated, without any record.
+	copy(data.Bytes()[numDataRecordsOffset:], pad("0", 8))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ra, err := NewRandomAccessReader(bytes.NewReader(data.Bytes()), int64(data.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ra.ReadAll(); err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("Reading no data records allocated %d bytes", allocated)
+	}
+
+	many := append([]byte(nil), data.Bytes()...)
+	copy(many[252:], pad("5000", 4))
+	if _, err := NewRandomAccessReader(bytes.NewReader(many), int64(len(many))); err == nil {
+		t.Error("Reading more signals than DefaultLimits should fail")
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Maximum difference in seconds between the onset of a data record and the end
+// of the previous one for the two to be considered contiguous.
+const mergeTolerance = 1e-3
+
+// Size in bytes of the annotation signal added to hold time-keeping
+// annotations.
+const timeKeepingSize = 32
+
+// Merge concatenates EDF files recorded one after the other, such as the
+// hourly files of a night, into a single recording. The files must have the
+// same version, signal definitions and data record duration. They are ordered
+// by start time, and their data records must not overlap.
+//
+// The result is continuous, and EDF+C for EDF+ files, when the data records
+// abut within a millisecond. Otherwise it is an EDF+D file, whose time-keeping
+// annotations give the onset of each data record: an annotation signal is added
+// for them to EDF files, whose identification fields are converted to the EDF+
+// format as needed. The onsets of the annotations of EDF+ files are made
+// relative to the start of the merged recording, their annotation signals being
+// enlarged to hold the longer onsets. The other header fields are the ones of
+// the earliest file.
+//
+// The samples are copied, so that the files are left unchanged.
+func Merge(files ...*Edf) (*Edf, error) {
+	if len(files) == 0 {
+		return nil, errors.New("No file to merge")
+	}
+	for i := 1; i < len(files); i++ {
+		differences, err := diffHeaders(files[0].Header, files[i].Header)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range differences {
+			if d.Signal >= 0 || d.Field == "Version" || d.Field == "NumSignals" || d.Field == "DurationDataRecords" {
+				return nil, fmt.Errorf("File %d cannot be merged with file 0: %v", i, d)
+			}
+		}
+	}
+
+	type input struct {
+		index int
+		edf   *Edf
+		start time.Time
+		// Offset in seconds from the start of the earliest file.
+		seconds int64
+	}
+	inputs := make([]input, len(files))
+	for i, e := range files {
+		start, err := e.Header.Start()
+		if err != nil {
+			return nil, err
+		}
+		inputs[i] = input{index: i, edf: e, start: start}
+	}
+	sort.SliceStable(inputs, func(i, j int) bool { return inputs[i].start.Before(inputs[j].start) })
+	for i := range inputs {
+		inputs[i].seconds = int64(math.Round(inputs[i].start.Sub(inputs[0].start).Seconds()))
+	}
+
+	// Onsets of the merged data records, since the start of the earliest file.
+	duration := float64(files[0].Header.DurationDataRecords)
+	var onsets []float64
+	continuous := true
+	end := math.Inf(-1)
+	for _, in := range inputs {
+		recordOnsets, err := in.edf.RecordOnsets()
+		if err != nil {
+			return nil, err
+		}
+		offset := in.start.Sub(inputs[0].start).Seconds()
+		for _, onset := range recordOnsets {
+			onset += offset
+			if onset < end-mergeTolerance {
+				return nil, fmt.Errorf("Data records of file %d overlap the ones of earlier files", in.index)
+			}
+			if len(onsets) > 0 && math.Abs(onset-onsets[0]-float64(len(onsets))*duration) > mergeTolerance {
+				continuous = false
+			}
+			onsets = append(onsets, onset)
+			end = onset + duration
+		}
+	}
+
+	header := *inputs[0].edf.Header
+	header.Signals = append([]SignalDefinition(nil), header.Signals...)
+	header.NumDataRecords = uint32(len(onsets))
+	plus := "EDF+"
+	if header.IsBDF() {
+		plus = "BDF+"
+	}
+	timeKeeping := -1
+	switch {
+	case continuous && header.isPlus():
+		header.Reserved = plus + "C"
+	case !continuous:
+		if !header.isPlus() {
+			plusIdentification(&header, inputs[0].start)
+		}
+		header.Reserved = plus + "D"
+		if header.AnnotationSignal() < 0 {
+			header.Signals = append(header.Signals, timeKeepingSignal(&header))
+			header.NumSignals++
+			header.HeaderSize = 256 * (header.NumSignals + 1)
+			timeKeeping = len(header.Signals) - 1
+		}
+	}
+
+	// Shifting the onsets of the annotations of EDF+ files lengthens them: the
+	// annotation signals are enlarged to hold the longest.
+	sampleSize := header.SampleSize()
+	for _, in := range inputs {
+		h := in.edf.Header
+		if in.seconds == 0 || !h.isPlus() {
+			continue
+		}
+		for i := range in.edf.Records {
+			for s := range h.Signals {
+				if !h.Signals[s].IsAnnotation() {
+					continue
+				}
+				size := len(shiftOnsets(AnnotationBytes(h, &in.edf.Records[i].Signals[s]), -in.seconds))
+				if samples := uint32((size + sampleSize - 1) / sampleSize); samples > header.Signals[s].SamplesRecord {
+					header.Signals[s].SamplesRecord = samples
+				}
+			}
+		}
+	}
+
+	merged := &Edf{Header: &header}
+	merged.allocateRecords(len(onsets))
+	k := 0
+	for _, in := range inputs {
+		h := in.edf.Header
+		for i := range in.edf.Records {
+			record := &merged.Records[k]
+			for s := range in.edf.Records[i].Signals {
+				signal := &in.edf.Records[i].Signals[s]
+				if in.seconds != 0 && h.isPlus() && h.Signals[s].IsAnnotation() {
+					if err := setAnnotationBytes(&header, &record.Signals[s], shiftOnsets(AnnotationBytes(h, signal), -in.seconds)); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				copy(record.Signals[s].Samples, signal.Samples)
+			}
+			if timeKeeping >= 0 {
+				onset := strconv.FormatFloat(math.Round(onsets[k]*1e6)/1e6, 'f', -1, 64)
+				if err := setAnnotationBytes(&header, &record.Signals[timeKeeping], []byte("+"+onset+"\x14\x14\x00")); err != nil {
+					return nil, err
+				}
+			}
+			k++
+		}
+	}
+	return merged, nil
+}
+
+// Converts the identification fields of the header that are not in the EDF+
+// format. Their subfields are unknown, except for the start date of the
+// recording, and are followed by the original field, truncated to fit in 80
+// bytes.
+func plusIdentification(header *Header, start time.Time) {
+	additional := func(field string) []string {
+		if field = strings.TrimSpace(field); field != "" {
+			return []string{field}
+		}
+		return nil
+	}
+	truncate := func(field string) string {
+		if len(field) > 80 {
+			return field[:80]
+		}
+		return field
+	}
+	if _, err := header.PatientInfo(); err != nil {
+		header.PatiendID = truncate((&PatientInfo{Additional: additional(header.PatiendID)}).String())
+	}
+	if _, err := header.RecordingInfo(); err != nil {
+		info := &RecordingInfo{StartDate: start, Additional: additional(header.RecordingID)}
+		header.RecordingID = truncate(info.String())
+	}
+}
+
+// Returns the definition of an annotation signal holding the time-keeping
+// annotations of the data records of header.
+func timeKeepingSignal(header *Header) SignalDefinition {
+	definition := SignalDefinition{Label: "EDF Annotations", PhysMin: -1, PhysMax: 1, DigiMin: -32768, DigiMax: 32767}
+	if header.IsBDF() {
+		definition.Label = "BDF Annotations"
+		definition.DigiMin, definition.DigiMax = -8388608, 8388607
+	}
+	sampleSize := uint32(header.SampleSize())
+	definition.SamplesRecord = (timeKeepingSize + sampleSize - 1) / sampleSize
+	return definition
+}
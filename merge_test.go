@@ -0,0 +1,167 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Returns the test file with the given number of data records, starting at
+// the given time.
+func testFileAt(t *testing.T, numRecords int, startTime string) *Edf {
+	data, _ := testFile(numRecords)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Header.StartTime = startTime
+	return e
+}
+
+func TestMerge(t *testing.T) {
+	a := testFileAt(t, 2, "10.20.30")
+	b := testFileAt(t, 3, "10.20.32")
+	e, err := Merge(b, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 5 || e.Header.StartTime != "10.20.30" || e.Header.Reserved != a.Header.Reserved {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	records := append(append([]Record(nil), a.Records...), b.Records...)
+	if !reflect.DeepEqual(e.Records, records) {
+		t.Errorf("%v should be equal to %v", e.Records, records)
+	}
+
+	// Gaps are recorded in an annotation signal, making an EDF+ file.
+	a.Header.PatiendID = "Haagse Harry"
+	a.Header.RecordingID = "PSG 1234"
+	b.Header.StartTime = "10.20.40"
+	if e, err = Merge(a, b); err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.Reserved != "EDF+D" || e.Header.NumSignals != 3 || !e.Header.Signals[2].IsAnnotation() {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	if e.Header.PatiendID != "X X X X Haagse_Harry" || e.Header.RecordingID != "Startdate 02-JAN-2017 X X X PSG_1234" {
+		t.Errorf("Unexpected identification %q, %q", e.Header.PatiendID, e.Header.RecordingID)
+	}
+	if problems := Validate(e.Header); len(problems) != 0 {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+	output := new(bytes.Buffer)
+	if err := Write(output, e); err != nil {
+		t.Fatal(err)
+	}
+	if e, err = Read(output); err != nil {
+		t.Fatal(err)
+	}
+	onsets, err := e.RecordOnsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float64{0, 1, 10, 11, 12}; !reflect.DeepEqual(onsets, expected) {
+		t.Errorf("Onsets %v should be equal to %v", onsets, expected)
+	}
+	if a.Header.NumSignals != 2 || len(a.Records[0].Signals) != 2 {
+		t.Error("Merged files should not be modified")
+	}
+
+	b.Header.StartTime = "10.20.31"
+	if _, err := Merge(a, b); err == nil {
+		t.Error("Merging overlapping files should fail")
+	}
+	b.Header.StartTime = "10.20.32"
+	b.Header.Signals[1].Label = "Thorax"
+	if _, err := Merge(a, b); err == nil {
+		t.Error("Merging files with different signals should fail")
+	}
+}
+
+func TestMergeEdfPlus(t *testing.T) {
+	a := testEdfPlus(t, "EDF+C", []string{"+0", "+1"})
+	b := testEdfPlus(t, "EDF+C", []string{"+0", "+1"})
+	b.Header.StartTime = "10.20.32"
+	e, err := Merge(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.Reserved != "EDF+C" || e.Header.NumDataRecords != 4 {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	tal := string(AnnotationBytes(e.Header, &e.Records[3].Signals[1]))
+	if !strings.HasPrefix(tal, "+3\x14\x14\x00+3\x150.5\x14Apnea\x14\x00") {
+		t.Errorf("Unexpected annotations %q", tal)
+	}
+	tal = string(AnnotationBytes(b.Header, &b.Records[1].Signals[1]))
+	if !strings.HasPrefix(tal, "+1\x14\x14") {
+		t.Errorf("Merged files should not be modified, annotations are %q", tal)
+	}
+
+	b.Header.StartTime = "10.20.35"
+	if e, err = Merge(a, b); err != nil {
+		t.Fatal(err)
+	}
+	onsets, err := e.RecordOnsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.Reserved != "EDF+D" || e.Header.NumSignals != 2 || !reflect.DeepEqual(onsets, []float64{0, 1, 5, 6}) {
+		t.Errorf("Unexpected header %+v with onsets %v", e.Header, onsets)
+	}
+}
+
+func TestMergeLongAnnotations(t *testing.T) {
+	// Files recorded 10 hours apart, whose annotation signals only hold their
+	// time-keeping annotations.
+	var files []*Edf
+	for _, startTime := range []string{"10.20.30", "20.20.30"} {
+		e := testEdfPlus(t, "EDF+C", []string{"+0", "+1"})
+		e.Header.StartTime = startTime
+		e.Header.Signals[1].SamplesRecord = 4
+		for i := range e.Records {
+			e.Records[i].Signals[1].Samples = make([]int32, 4)
+			if err := setAnnotationBytes(e.Header, &e.Records[i].Signals[1], []byte(fmt.Sprintf("+%d\x14\x14\x00", i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		files = append(files, e)
+	}
+	e, err := Merge(files...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.Signals[1].SamplesRecord != 5 || files[1].Header.Signals[1].SamplesRecord != 4 {
+		t.Errorf("Unexpected annotation signal %+v", e.Header.Signals[1])
+	}
+	output := new(bytes.Buffer)
+	if err := Write(output, e); err != nil {
+		t.Fatal(err)
+	}
+	if e, err = Read(output); err != nil {
+		t.Fatal(err)
+	}
+	onsets, err := e.RecordOnsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float64{0, 1, 36000, 36001}; !reflect.DeepEqual(onsets, expected) {
+		t.Errorf("Onsets %v should be equal to %v", onsets, expected)
+	}
+}
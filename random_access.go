@@ -0,0 +1,161 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A RandomAccessReader reads arbitrary data records of an EDF file without
+// decoding the records before them. Data records have a fixed size, so the
+// position of any record can be computed from the header.
+type RandomAccessReader struct {
+	// Workers is the number of goroutines decoding data records concurrently
+	// in ReadRecords and ReadAll. Records are decoded sequentially when it is
+	// lower than 2.
+	Workers int
+
+	r      io.ReaderAt
+	size   int64
+	header *Header
+	layout *recordLayout
+}
+
+// NewRandomAccessReader reads the header of the EDF file from r, which is
+// assumed to have the given size in bytes. When the header does not declare
+// the number of data records, it is inferred from the size. Otherwise, the
+// data records declared must fit in the size, so that reading them never
+// allocates more than the size of the file. The header is checked against
+// DefaultLimits, except for their MaxBytes.
+func NewRandomAccessReader(r io.ReaderAt, size int64) (*RandomAccessReader, error) {
+	header, err := readHeader(bufio.NewReader(io.NewSectionReader(r, 0, size)), sizedDefaultLimits())
+	if err != nil {
+		return nil, err
+	}
+	dataSize := size - int64(header.HeaderSize)
+	if header.NumDataRecords == UnknownNumDataRecords {
+		if dataSize < 0 || header.RecordSize() == 0 || dataSize%header.RecordSize() != 0 {
+			return nil, fieldError("NumDataRecords", -1, len(header.Signals),
+				fmt.Errorf("Data size %d is not a multiple of the record size %d", dataSize, header.RecordSize()))
+		}
+		header.NumDataRecords = uint32(dataSize / header.RecordSize())
+	} else if header.NumDataRecords > 0 && header.RecordSize() == 0 {
+		return nil, fieldError("NumDataRecords", -1, len(header.Signals),
+			fmt.Errorf("%d data records without samples", header.NumDataRecords))
+	} else if header.DataSize() > dataSize {
+		return nil, fieldError("NumDataRecords", -1, len(header.Signals),
+			fmt.Errorf("%d bytes of data records, expected %d for %d records of %d bytes",
+				dataSize, header.DataSize(), header.NumDataRecords, header.RecordSize()))
+	}
+	return &RandomAccessReader{r: r, size: size, header: header, layout: newRecordLayout(header, nil)}, nil
+}
+
+// Header returns the header of the EDF file.
+func (ra *RandomAccessReader) Header() *Header {
+	return ra.header
+}
+
+// NumRecords returns the number of data records in the file.
+func (ra *RandomAccessReader) NumRecords() int {
+	return int(ra.header.NumDataRecords)
+}
+
+// ReadRecord reads the n-th data record of the file.
+func (ra *RandomAccessReader) ReadRecord(n int) (*Record, error) {
+	records, err := ra.ReadRecords(n, 1)
+	if err != nil {
+		return nil, err
+	}
+	return &records[0], nil
+}
+
+// ReadRecords reads count consecutive data records, starting at the first-th
+// record of the file.
+func (ra *RandomAccessReader) ReadRecords(first, count int) ([]Record, error) {
+	e, err := ra.readRange(first, count)
+	if err != nil {
+		return nil, err
+	}
+	return e.Records, nil
+}
+
+// ReadAll reads all the data records of the file.
+func (ra *RandomAccessReader) ReadAll() (*Edf, error) {
+	return ra.readRange(0, ra.NumRecords())
+}
+
+// Reads count consecutive data records, starting at the first-th record of the
+// file, into contiguous columns of samples.
+func (ra *RandomAccessReader) readRange(first, count int) (*Edf, error) {
+	if first < 0 || count < 0 || first > ra.NumRecords() || count > ra.NumRecords()-first {
+		return nil, fmt.Errorf("Records [%d, %d) out of range [0, %d)", first, first+count, ra.NumRecords())
+	}
+	e := &Edf{Header: ra.header}
+	e.allocateRecords(count)
+	records := e.Records
+	workers := ra.Workers
+	if workers > count {
+		workers = count
+	}
+	if workers < 2 {
+		if err := ra.readRecords(first, records); err != nil {
+			return nil, err
+		}
+		return e, nil
+	}
+
+	// Each worker decodes a contiguous range of records in place.
+	errs := make([]error, workers)
+	chunk := (count + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := 0; w*chunk < count; w++ {
+		start, end := w*chunk, (w+1)*chunk
+		if end > count {
+			end = count
+		}
+		wg.Add(1)
+		go func(w, start, end int) {
+			defer wg.Done()
+			errs[w] = ra.readRecords(first+start, records[start:end])
+		}(w, start, end)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Reads len(records) consecutive data records, starting at the first-th record.
+func (ra *RandomAccessReader) readRecords(first int, records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	recordSize := ra.header.RecordSize()
+	offset := int64(ra.header.HeaderSize) + int64(first)*recordSize
+	input := bufio.NewReader(io.NewSectionReader(ra.r, offset, int64(len(records))*recordSize))
+	data := make([]byte, recordSize)
+	for i := range records {
+		if err := readRecord(input, ra.layout, data, &records[i]); err != nil {
+			return recordError(ra.header, first+i, err)
+		}
+	}
+	return nil
+}
@@ -18,255 +18,316 @@ package edf
 import (
 	"bufio"
 	"encoding/binary"
+	"errors"
+	"fmt"
 	"io"
-	"log"
 	"os"
-	"strconv"
-	"strings"
 )
 
-func init() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-}
-
 // ReadEDF reads an EDF file.
 func ReadEDF(filename string) (*Edf, error) {
-	var err error
-
 	fileInput, err := os.Open(filename)
 	if err != nil {
-		log.Printf("Error: %v\n", err)
 		return nil, err
 	}
-
 	defer fileInput.Close()
-	input := bufio.NewReader(fileInput)
-	edf := &Edf{}
-	edf.Header, err = readHeader(input)
-	if err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	if err := readRecords(input, edf); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
 
-	return edf, nil
+	return Read(fileInput)
 }
 
-func readNextBytes(input io.Reader, size uint) ([]byte, error) {
-	data := make([]byte, size)
-	_, err := io.ReadFull(input, data)
-	return data, err
+// Read reads an EDF file from r.
+func Read(r io.Reader) (*Edf, error) {
+	return NewDecoder(r).Decode()
 }
 
-// Reads the header of the EDF+ file.
-func readHeader(input io.Reader) (*Header, error) {
-	header := Header{}
-	var data []byte
-	var iData uint64
-	var fData float64
-	var sData string
-	var err error
-
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
+// ReadHeader reads the header of an EDF file, without reading its data records.
+func ReadHeader(filename string) (*Header, error) {
+	fileInput, err := os.Open(filename)
+	if err != nil {
 		return nil, err
 	}
-	header.Version = strings.TrimSpace(string(data))
+	defer fileInput.Close()
 
-	if data, err = readNextBytes(input, 80); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	header.PatiendID = strings.TrimSpace(string(data))
+	return ReadHeaderFrom(fileInput)
+}
 
-	if data, err = readNextBytes(input, 80); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	header.RecordingID = strings.TrimSpace(string(data))
+// ReadHeaderFrom reads the header of an EDF file from r, without reading its
+// data records.
+func ReadHeaderFrom(r io.Reader) (*Header, error) {
+	return NewDecoder(r).DecodeHeader()
+}
 
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	header.StartDate = strings.TrimSpace(string(data))
+// A Decoder reads and decodes an EDF file from an input stream.
+type Decoder struct {
+	// Lenient enables the recovery of slightly malformed files: numbers using a
+	// comma as decimal separator and fields padded with NUL bytes are accepted,
+	// and a truncated last data record is dropped instead of failing. Each
+	// recovery is reported by Warnings.
+	Lenient bool
+
+	// Filter restricts decoding to the signals it selects. The header of the
+	// decoded file only defines those signals, and the bytes of the other ones
+	// are skipped. EDF+ files remain valid only if their annotation signal is
+	// selected. All the signals are decoded when Filter is nil.
+	Filter SignalFilter
+
+	// Limits bounds the memory allocated by the decoder. DefaultLimits are
+	// used when nil, except for their MaxBytes when the size of the input is
+	// known, as it bounds the data records already.
+	Limits *Limits
+
+	r            *bufio.Reader
+	decompressed bool
+	size         int64
+	warnings     []Problem
+}
 
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	header.StartTime = strings.TrimSpace(string(data))
+// NewDecoder returns a new decoder that reads from r. The decoder introduces
+// its own buffering, and transparently decompresses gzip, bzip2 and zlib
+// compressed input.
+//
+// When the size of the input can be known without reading it, as for files,
+// the data records declared by the header are checked against it before being
+// allocated.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r), size: inputSize(r)}
+}
 
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	iData, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+// Decode reads the header and all the data records from the input.
+func (d *Decoder) Decode() (*Edf, error) {
+	header, signals, err := d.decodeHeader()
 	if err != nil {
-		log.Printf("Error: %v\n", err)
 		return nil, err
 	}
-	header.HeaderSize = uint32(iData)
 
-	if data, err = readNextBytes(input, 44); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
+	edf := &Edf{Header: header}
+	if signals != nil {
+		edf.Header = header.withSignals(signals)
 	}
-	header.Reserved = strings.TrimSpace(string(data))
-
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
+	available := int64(-1)
+	if d.size >= 0 {
+		available = d.size - 256*int64(len(header.Signals)+1)
 	}
-	iData, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
-	if err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
+	if err := readRecords(d.r, header, signals, edf, available, d.limits()); err != nil {
+		if !d.Lenient || !(errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+			return nil, err
+		}
+		d.warnings = append(d.warnings, Problem{"NumDataRecords", -1, SeverityWarning,
+			fmt.Sprintf("File truncated after %d complete data records", len(edf.Records))})
+		edf.Header.NumDataRecords = uint32(len(edf.Records))
 	}
-	header.NumDataRecords = uint32(iData)
 
-	if data, err = readNextBytes(input, 8); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
-	}
-	fData, err = strconv.ParseFloat(strings.TrimSpace(string(data)), 32)
+	return edf, nil
+}
+
+// DecodeHeader reads only the header from the input. The data records are left
+// unread, so that listing the signals of a large file does not depend on its
+// size.
+func (d *Decoder) DecodeHeader() (*Header, error) {
+	header, signals, err := d.decodeHeader()
 	if err != nil {
-		log.Printf("Error: %v\n", err)
 		return nil, err
 	}
-	header.DurationDataRecords = float32(fData)
+	if signals != nil {
+		return header.withSignals(signals), nil
+	}
+	return header, nil
+}
 
-	if data, err = readNextBytes(input, 4); err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
+// Reads and parses the header, returning it along with the indices of the
+// signals selected by the filter (nil if there is no filter).
+func (d *Decoder) decodeHeader() (*Header, []int, error) {
+	d.warnings = nil
+	// The input is only checked for compression once, so that decoding again
+	// reads the following bytes of the same stream.
+	if !d.decompressed {
+		r, compressed, err := decompress(d.r)
+		if err != nil {
+			return nil, nil, err
+		}
+		d.r, d.decompressed = r, true
+		if compressed {
+			d.size = -1
+		}
 	}
-	iData, err = strconv.ParseUint(strings.TrimSpace(string(data)), 10, 32)
+	raw, err := readRawHeader(d.r, d.limits())
 	if err != nil {
-		log.Printf("Error: %v\n", err)
-		return nil, err
+		return nil, nil, err
 	}
-	header.NumSignals = uint32(iData)
-
-	header.Signals = make([]SignalDefinition, header.NumSignals)
-
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 16); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
-		}
-		signal.Label = strings.TrimSpace(string(data))
+	p := &headerParser{lenient: d.Lenient}
+	header := p.parse(raw)
+	if len(p.errs) > 0 {
+		return nil, nil, p.errs[0]
 	}
-
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 80); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
+	if err := checkHeaderSize(header); err != nil {
+		if !d.Lenient {
+			return nil, nil, err
 		}
-		signal.TransducerType = strings.TrimSpace(string(data))
+		p.warnings = append(p.warnings, Problem{"HeaderSize", -1, SeverityWarning, err.(*ParseError).Err.Error()})
 	}
-
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
-		}
-		signal.PhysicalDimension = strings.TrimSpace(string(data))
+	if err := d.limits().checkRecordSize(header); err != nil {
+		return nil, nil, err
 	}
+	d.warnings = p.warnings
 
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
-		}
-		signal.PhysicalMinimum = strings.TrimSpace(string(data))
+	var signals []int
+	if d.Filter != nil {
+		signals = selectSignals(header, d.Filter)
 	}
+	return header, signals, nil
+}
 
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
-		}
-		signal.PhysicalMaximum = strings.TrimSpace(string(data))
+// Returns the limits of the decoder.
+func (d *Decoder) limits() *Limits {
+	if d.Limits != nil {
+		return d.Limits
 	}
-
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
-		}
-		signal.DigitalMinimum = strings.TrimSpace(string(data))
+	if d.size >= 0 {
+		return sizedDefaultLimits()
 	}
+	return &DefaultLimits
+}
 
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
+// Warnings returns the problems recovered from by the last call to Decode in
+// lenient mode.
+func (d *Decoder) Warnings() []Problem {
+	return d.warnings
+}
+
+// Reads the data records from the EDF+ file described by header, decoding the
+// given signals (all of them if nil). The header of the edf must be the one of
+// the decoded signals. If the number of data records is unknown, records are
+// read until the end of the input and the header of the edf is updated
+// accordingly. On error, the records of the edf are the ones completely read.
+//
+// The declared records are checked against limits, unless nil, and the
+// available bytes of the input, which are unknown if negative. They are
+// allocated at once only if the input is known to hold them. Otherwise they are
+// allocated as they are read, failing once they exceed the limits.
+func readRecords(input *bufio.Reader, header *Header, signals []int, edf *Edf, available int64, limits *Limits) error {
+	recordSize := header.RecordSize()
+	n := edf.Header.NumDataRecords
+	// Records without samples cannot be checked against the size of the input.
+	if n != UnknownNumDataRecords && n > 0 && recordSize == 0 {
+		return fieldError("NumDataRecords", -1, len(header.Signals), fmt.Errorf("%d data records without samples", n))
+	}
+	// A data record larger than the rest of the input cannot be read, and is
+	// not allocated.
+	if available >= 0 && recordSize > available && n != 0 && !(n == UnknownNumDataRecords && available == 0) {
+		return recordError(header, 0, fmt.Errorf("Data records of %d bytes exceed the %d bytes left in the input: %w",
+			recordSize, available, io.ErrUnexpectedEOF))
+	}
+	fits := available < 0 || header.DataSize() <= available
+	if n != UnknownNumDataRecords && fits {
+		if err := limits.checkDataSize(edf.Header); err != nil {
+			return err
 		}
-		signal.DigitalMaximum = strings.TrimSpace(string(data))
 	}
-
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 80); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
+	data := make([]byte, recordSize)
+	layout := newRecordLayout(header, signals)
+	// The records of an input of unknown size are only allocated as they are
+	// read, so that a header declaring them does not suffice.
+	if n != UnknownNumDataRecords && available >= 0 && fits {
+		edf.allocateRecords(int(n))
+		for i := uint32(0); i < n; i++ {
+			if err := readRecord(input, layout, data, &edf.Records[i]); err != nil {
+				edf.Records = edf.Records[:i]
+				return recordError(header, int(i), err)
+			}
 		}
-		signal.Prefiltering = strings.TrimSpace(string(data))
+		return nil
 	}
 
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 8); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
+	unknown := n == UnknownNumDataRecords
+	edf.Records = []Record{}
+	for unknown && !atEOF(input) || !unknown && len(edf.Records) < int(n) {
+		if limits != nil && limits.MaxBytes > 0 && int64(len(edf.Records)+1)*decodedRecordSize(edf.Header) > limits.MaxBytes {
+			return recordError(header, len(edf.Records), fmt.Errorf("Data records exceed the limit of %d bytes", limits.MaxBytes))
 		}
-		sData = strings.TrimSpace(string(data))
-		if iData, err = strconv.ParseUint(sData, 10, 32); err != nil {
-			log.Printf("Error at signal %d: %v\n", signalIndex, err)
-			return nil, err
+		record := Record{}
+		if err := readRecord(input, layout, data, &record); err != nil {
+			return recordError(header, len(edf.Records), err)
 		}
-		signal.SamplesRecord = uint32(iData)
+		edf.Records = append(edf.Records, record)
 	}
+	if unknown {
+		edf.Header.NumDataRecords = uint32(len(edf.Records))
+	}
+	return nil
+}
 
-	for signalIndex := uint32(0); signalIndex < header.NumSignals; signalIndex++ {
-		signal := &header.Signals[signalIndex]
-		if data, err = readNextBytes(input, 32); err != nil {
-			log.Printf("Error: %v\n", err)
-			return nil, err
+// Returns whether there is no more data to read from input.
+func atEOF(input *bufio.Reader) bool {
+	_, err := input.Peek(1)
+	return err == io.EOF
+}
+
+// recordLayout locates the decoded signals in the bytes of a data record. It is
+// computed once per file, so that decoding a data record only allocates its
+// samples.
+type recordLayout struct {
+	sampleSize int
+	// Offset in the data record, and number of samples, of each decoded signal.
+	offsets []int
+	samples []int
+	total   int
+}
+
+// Returns the layout of the given signals (all of them if nil) in the data
+// records described by header.
+func newRecordLayout(header *Header, signals []int) *recordLayout {
+	if signals == nil {
+		signals = make([]int, len(header.Signals))
+		for s := range signals {
+			signals[s] = s
 		}
-		signal.Reserved = strings.TrimSpace(string(data))
 	}
+	l := &recordLayout{sampleSize: header.SampleSize(), offsets: make([]int, len(signals)), samples: make([]int, len(signals))}
+	offsets := make([]int, len(header.Signals))
+	for s := 1; s < len(offsets); s++ {
+		offsets[s] = offsets[s-1] + l.sampleSize*int(header.Signals[s-1].SamplesRecord)
+	}
+	for i, s := range signals {
+		l.offsets[i] = offsets[s]
+		l.samples[i] = int(header.Signals[s].SamplesRecord)
+		l.total += l.samples[i]
+	}
+	return l
+}
 
-	return &header, nil
+// Reads a single data record into record, decoding the signals of the layout.
+// The data buffer must be of the size of a record.
+func readRecord(input io.Reader, layout *recordLayout, data []byte, record *Record) error {
+	if _, err := io.ReadFull(input, data); err != nil {
+		return err
+	}
+	layout.decode(data, record)
+	return nil
 }
 
-// Reads the data records from the EDF+ file. The header of the edf must be
-// parsed and filled.
-func readRecords(input io.Reader, edf *Edf) error {
-	edf.Records = make([]Record, edf.Header.NumDataRecords)
-	for i := uint32(0); i < edf.Header.NumDataRecords; i++ {
-		record := &edf.Records[i]
-		record.Signals = make([]SignalRecord, edf.Header.NumSignals)
-		for s := uint32(0); s < edf.Header.NumSignals; s++ {
-			signal := &record.Signals[s]
-			signal.Samples = make([]int16, edf.Header.Signals[s].SamplesRecord)
-			for d := uint32(0); d < edf.Header.Signals[s].SamplesRecord; d++ {
-				err := binary.Read(input, binary.LittleEndian, &signal.Samples[d])
-				if err != nil {
-					return err
-				}
+// Decodes the signals of the layout from the bytes of a data record into
+// record. Unless they are already allocated, the samples of all the signals
+// are allocated at once.
+func (l *recordLayout) decode(data []byte, record *Record) {
+	if record.Signals == nil {
+		samples := make([]int32, l.total)
+		record.Signals = make([]SignalRecord, len(l.samples))
+		for i, n := range l.samples {
+			record.Signals[i].Samples = samples[:n:n]
+			samples = samples[n:]
+		}
+	}
+	for i := range record.Signals {
+		samples := record.Signals[i].Samples
+		data := data[l.offsets[i]:]
+		if l.sampleSize == 3 {
+			for j := range samples {
+				samples[j] = int32(uint32(data[3*j])<<8|uint32(data[3*j+1])<<16|uint32(data[3*j+2])<<24) >> 8
+			}
+		} else {
+			for j := range samples {
+				samples[j] = int32(int16(binary.LittleEndian.Uint16(data[2*j:])))
 			}
 		}
 	}
-	return nil
 }
@@ -0,0 +1,582 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// pad left-aligns s in a space padded field of the given width.
+func pad(s string, width int) string {
+	return fmt.Sprintf("%-*s", width, s)
+}
+
+// testFile returns the bytes of a small EDF file with two signals of 4 and 2
+// samples per record, along with the records it contains.
+func testFile(numRecords int) ([]byte, []Record) {
+	samples := []int{4, 2}
+	buf := new(bytes.Buffer)
+	buf.WriteString(pad("0", 8))
+	buf.WriteString(pad("X X X X", 80))
+	buf.WriteString(pad("Startdate 02-JAN-2017 X X X", 80))
+	buf.WriteString("02.01.17")
+	buf.WriteString("10.20.30")
+	buf.WriteString(pad(fmt.Sprint(256*(len(samples)+1)), 8))
+	buf.WriteString(pad("", 44))
+	buf.WriteString(pad(fmt.Sprint(numRecords), 8))
+	buf.WriteString(pad("1", 8))
+	buf.WriteString(pad(fmt.Sprint(len(samples)), 4))
+	for _, f := range []struct {
+		width  int
+		values []string
+	}{
+		{16, []string{"EEG Fpz-Cz", "Resp"}},
+		{80, []string{"AgAgCl electrode", "Thermistor"}},
+		{8, []string{"uV", "degC"}},
+		{8, []string{"-100", "34"}},
+		{8, []string{"100", "40"}},
+		{8, []string{"-32768", "-2048"}},
+		{8, []string{"32767", "2047"}},
+		{80, []string{"HP:0.1Hz", ""}},
+		{8, []string{"4", "2"}},
+		{32, []string{"", ""}},
+	} {
+		for _, v := range f.values {
+			buf.WriteString(pad(v, f.width))
+		}
+	}
+
+	records := make([]Record, numRecords)
+	for i := range records {
+		records[i].Signals = make([]SignalRecord, len(samples))
+		for s, n := range samples {
+			data := make([]int32, n)
+			for j := range data {
+				data[j] = int32(1000*i + 100*s + j - 50)
+				binary.Write(buf, binary.LittleEndian, int16(data[j]))
+			}
+			records[i].Signals[s].Samples = data
+		}
+	}
+	return buf.Bytes(), records
+}
+
+func TestRead(t *testing.T) {
+	data, records := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 3 || e.Header.NumSignals != 2 || e.Header.HeaderSize != 768 {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	if e.Header.StartDate != "02.01.17" || e.Header.Signals[1].Label != "Resp" {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	if !reflect.DeepEqual(e.Records, records) {
+		t.Errorf("%v should be equal to %v", e.Records, records)
+	}
+
+	if _, err := Read(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Error("Reading a truncated file should fail")
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	data, _ := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The data records are not read, so they may be missing.
+	header, err := ReadHeaderFrom(bytes.NewReader(data[:768]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(header, e.Header) {
+		t.Errorf("%+v should be equal to %+v", header, e.Header)
+	}
+	if header.DataSize() != 36 {
+		t.Errorf("DataSize is %d, should be 36", header.DataSize())
+	}
+
+	copy(data[numDataRecordsOffset:], pad("-1", 8))
+	if header, err = ReadHeaderFrom(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	if header.DataSize() != -1 {
+		t.Errorf("DataSize is %d, should be -1", header.DataSize())
+	}
+
+	if _, err := ReadHeaderFrom(bytes.NewReader(data[:767])); err == nil {
+		t.Error("Reading a truncated header should fail")
+	}
+}
+
+func TestRecordReader(t *testing.T) {
+	data, records := testFile(3)
+	rr, err := NewRecordReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rr.Header().NumDataRecords != 3 {
+		t.Errorf("Unexpected header %+v", rr.Header())
+	}
+	n := 0
+	for rr.NextRecord() {
+		if rr.Index() != n {
+			t.Errorf("Index is %d, should be %d", rr.Index(), n)
+		}
+		if !reflect.DeepEqual(*rr.Record(), records[n]) {
+			t.Errorf("%v should be equal to %v", *rr.Record(), records[n])
+		}
+		n++
+	}
+	if err := rr.Err(); err != nil {
+		t.Error(err)
+	}
+	if n != len(records) {
+		t.Errorf("Read %d records, should be %d", n, len(records))
+	}
+
+	rr, err = NewRecordReader(bytes.NewReader(data[:len(data)-1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rr.NextRecord() {
+	}
+	if rr.Err() == nil {
+		t.Error("Reading a truncated file should fail")
+	}
+}
+
+func TestRandomAccessReader(t *testing.T) {
+	data, records := testFile(5)
+	ra, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.NumRecords() != 5 {
+		t.Errorf("NumRecords is %d, should be 5", ra.NumRecords())
+	}
+	record, err := ra.ReadRecord(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*record, records[3]) {
+		t.Errorf("%v should be equal to %v", *record, records[3])
+	}
+	actual, err := ra.ReadRecords(1, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, records[1:4]) {
+		t.Errorf("%v should be equal to %v", actual, records[1:4])
+	}
+	if _, err := ra.ReadRecords(4, 2); err == nil {
+		t.Error("Reading records past the end should fail")
+	}
+	if _, err := ra.ReadRecords(1, math.MaxInt); err == nil {
+		t.Error("Reading too many records should fail")
+	}
+
+	copy(data[184:], pad("256", 8))
+	if _, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data))); err == nil {
+		t.Error("Reading a file with an invalid header size should fail")
+	}
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Decoding a file with an invalid header size should fail")
+	}
+	if _, err := NewRecordReader(bytes.NewReader(data)); err == nil {
+		t.Error("Streaming a file with an invalid header size should fail")
+	}
+	d := NewDecoder(bytes.NewReader(data))
+	d.Lenient = true
+	if _, err := d.Decode(); err != nil || len(d.Warnings()) != 1 || d.Warnings()[0].Field != "HeaderSize" {
+		t.Errorf("Decoding an invalid header size leniently failed with %v, warnings %v", err, d.Warnings())
+	}
+}
+
+func TestReadUnknownNumDataRecords(t *testing.T) {
+	data, records := testFile(3)
+	copy(data[numDataRecordsOffset:], pad("-1", 8))
+
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 3 || !reflect.DeepEqual(e.Records, records) {
+		t.Errorf("%d records %v should be equal to %v", e.Header.NumDataRecords, e.Records, records)
+	}
+
+	rr, err := NewRecordReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for ; rr.NextRecord(); n++ {
+	}
+	if rr.Err() != nil || n != 3 {
+		t.Errorf("Read %d records (error %v), should be 3", n, rr.Err())
+	}
+
+	ra, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ra.NumRecords() != 3 {
+		t.Errorf("NumRecords is %d, should be 3", ra.NumRecords())
+	}
+
+	if _, err := Read(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Error("Reading a truncated file should fail")
+	}
+
+	// Samples per record of both signals, with a trailing byte.
+	empty := append(data[:768:768], 0)
+	copy(empty[256+2*(16+80+8+8+8+8+8+80):], pad("0", 8)+pad("0", 8))
+	if _, err := Read(bytes.NewReader(empty)); err == nil {
+		t.Error("Reading an unknown number of records without samples should fail")
+	}
+	if _, err := NewRecordReader(bytes.NewReader(empty)); err == nil {
+		t.Error("Reading an unknown number of records without samples should fail")
+	}
+	if _, err := NewRandomAccessReader(bytes.NewReader(empty), int64(len(empty))); err == nil {
+		t.Error("Reading an unknown number of records without samples should fail")
+	}
+}
+
+func TestDecodeLenient(t *testing.T) {
+	data, records := testFile(3)
+	copy(data[244:], pad("0,5", 8))
+	// Physical minimum of the second signal.
+	copy(data[256+2*(16+80+8)+8:], "34\x00\x00\x00\x00\x00\x00")
+	data = data[:len(data)-1]
+
+	if _, err := Read(bytes.NewReader(data)); err == nil {
+		t.Error("Reading a malformed file should fail")
+	}
+
+	d := NewDecoder(bytes.NewReader(data))
+	d.Lenient = true
+	e, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.DurationDataRecords != 0.5 || e.Header.Signals[1].PhysicalMinimum != "34" {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	if e.Header.NumDataRecords != 2 || !reflect.DeepEqual(e.Records, records[:2]) {
+		t.Errorf("%v should be equal to %v", e.Records, records[:2])
+	}
+	expected := []Problem{
+		{"DurationDataRecords", -1, SeverityWarning, "Comma used as decimal separator in 0,5"},
+		{"PhysicalMinimum", 1, SeverityWarning, "Field padded with NUL bytes"},
+		{"NumDataRecords", -1, SeverityWarning, "File truncated after 2 complete data records"},
+	}
+	if !reflect.DeepEqual(d.Warnings(), expected) {
+		t.Errorf("%v should be equal to %v", d.Warnings(), expected)
+	}
+}
+
+func TestParseError(t *testing.T) {
+	data, _ := testFile(3)
+	for _, test := range []struct {
+		data     []byte
+		expected ParseError
+	}{
+		{data[:100], ParseError{"RecordingID", -1, -1, 100, io.ErrUnexpectedEOF}},
+		{data[:500], ParseError{"DigitalMinimum", 0, -1, 500, io.ErrUnexpectedEOF}},
+		{data[:len(data)-1], ParseError{"Records", -1, 2, 792, io.ErrUnexpectedEOF}},
+	} {
+		_, err := Read(bytes.NewReader(test.data))
+		pe, ok := err.(*ParseError)
+		if !ok || *pe != test.expected {
+			t.Errorf("%v should be equal to %v", err, &test.expected)
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("%v should wrap %v", err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	// Samples per record of the second signal.
+	copy(data[696:], pad("two", 8))
+	_, err := Read(bytes.NewReader(data))
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Field != "SamplesRecord" || pe.Signal != 1 || pe.Offset != 696 {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func TestCalibration(t *testing.T) {
+	data, _ := testFile(1)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := e.Header.Signals[1]
+	if s.PhysMin != 34 || s.PhysMax != 40 || s.DigiMin != -2048 || s.DigiMax != 2047 {
+		t.Errorf("Unexpected calibration %+v", s)
+	}
+	if s.Gain() != 6.0/4095 || s.Offset() != 34+2048*6.0/4095 {
+		t.Errorf("Unexpected gain %v and offset %v", s.Gain(), s.Offset())
+	}
+
+	// Digital maximum of the first signal, then of the second one. A degenerate
+	// range is only accepted in lenient mode, and decimal digital values are
+	// accepted.
+	copy(data[256+2*(16+80+8+8+8+8):], pad("-32768", 8))
+	copy(data[256+2*(16+80+8+8+8+8)+8:], pad("2047.0", 8))
+	_, err = Read(bytes.NewReader(data))
+	if pe, ok := err.(*ParseError); !ok || pe.Field != "DigitalMaximum" || pe.Signal != 0 {
+		t.Errorf("Unexpected error %v", err)
+	}
+	d := NewDecoder(bytes.NewReader(data))
+	d.Lenient = true
+	if e, err = d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	if s := e.Header.Signals; s[0].DigiMax != -32768 || s[1].DigiMax != 2047 || s[1].DigitalMaximum != "2047.0" {
+		t.Errorf("Unexpected signals %+v", s)
+	}
+	warnings := []Problem{{"DigitalMaximum", 0, SeverityWarning, "Digital minimum and maximum are both -32768"}}
+	if !reflect.DeepEqual(d.Warnings(), warnings) {
+		t.Errorf("%v should be equal to %v", d.Warnings(), warnings)
+	}
+	problems := Validate(e.Header)
+	expected := []Problem{
+		{"DigitalMaximum", 0, SeverityError, "Digital minimum -32768 is not lower than digital maximum -32768"},
+		{"DigitalMaximum", 1, SeverityWarning, `"2047.0" is not an integer`},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("%v should be equal to %v", problems, expected)
+	}
+
+	copy(data[256+2*(16+80+8+8+8+8):], pad("32767", 8))
+	copy(data[256+2*(16+80+8+8+8+8)+8:], pad("2047.5", 8))
+	_, err = Read(bytes.NewReader(data))
+	if pe, ok := err.(*ParseError); !ok || pe.Field != "DigitalMaximum" || pe.Signal != 1 {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+// repeatReader returns a header followed by the same data record repeated.
+type repeatReader struct {
+	header  []byte
+	record  []byte
+	count   int
+	written int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		if len(r.header) > 0 {
+			c := copy(p[n:], r.header)
+			r.header = r.header[c:]
+			n += c
+			continue
+		}
+		if r.count == 0 {
+			if n == 0 {
+				return 0, io.EOF
+			}
+			break
+		}
+		c := copy(p[n:], r.record[r.written:])
+		n += c
+		if r.written += c; r.written == len(r.record) {
+			r.written = 0
+			r.count--
+		}
+	}
+	return n, nil
+}
+
+// benchmarkFile returns a reader over an EDF file of 256 signals sampled at
+// 256Hz in one-second data records, and the size of the file.
+func benchmarkFile(b *testing.B, duration time.Duration) (func() io.Reader, int64) {
+	header := &Header{Version: "0", StartDate: "02.01.17", StartTime: "10.20.30", DurationDataRecords: 1, NumSignals: 256}
+	header.NumDataRecords = uint32(duration / time.Second)
+	for i := 0; i < 256; i++ {
+		header.Signals = append(header.Signals, SignalDefinition{
+			Label: fmt.Sprintf("EEG %d", i), PhysMin: -100, PhysMax: 100, DigiMin: -32768, DigiMax: 32767, SamplesRecord: 256})
+	}
+	headerBytes := new(bytes.Buffer)
+	if err := writeHeader(headerBytes, header); err != nil {
+		b.Fatal(err)
+	}
+	record := make([]byte, header.RecordSize())
+	rand.Read(record)
+	size := int64(headerBytes.Len()) + int64(header.NumDataRecords)*int64(len(record))
+	return func() io.Reader {
+		return &repeatReader{header: headerBytes.Bytes(), record: record, count: int(header.NumDataRecords)}
+	}, size
+}
+
+func BenchmarkRead(b *testing.B) {
+	file, size := benchmarkFile(b, 10*time.Minute)
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Read(file()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRecordReader(b *testing.B) {
+	file, size := benchmarkFile(b, 3*time.Hour)
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		rr, err := NewRecordReader(file())
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rr.NextRecord() {
+		}
+		if err := rr.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestRandomAccessReaderWorkers(t *testing.T) {
+	data, records := testFile(10)
+	ra, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 4, 20} {
+		ra.Workers = workers
+		e, err := ra.ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(e.Records, records) {
+			t.Errorf("%d workers: %v should be equal to %v", workers, e.Records, records)
+		}
+	}
+
+	ra, err = NewRandomAccessReader(bytes.NewReader(data[:len(data)-1]), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ra.Workers = 4
+	_, err = ra.ReadAll()
+	if pe, ok := err.(*ParseError); !ok || pe.Record != 9 {
+		t.Errorf("Unexpected error %v", err)
+	}
+}
+
+func BenchmarkRandomAccessReader(b *testing.B) {
+	file, size := benchmarkFile(b, 10*time.Minute)
+	data, err := ioutil.ReadAll(file())
+	if err != nil {
+		b.Fatal(err)
+	}
+	ra, err := NewRandomAccessReader(bytes.NewReader(data), size)
+	if err != nil {
+		b.Fatal(err)
+	}
+	ra.Workers = runtime.GOMAXPROCS(0)
+	b.SetBytes(size)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ra.ReadAll(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSamples(t *testing.T) {
+	data, records := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected []int32
+	for _, record := range records {
+		expected = append(expected, record.Signals[1].Samples...)
+	}
+	samples := e.Samples(1)
+	if !reflect.DeepEqual(samples, expected) {
+		t.Errorf("%v should be equal to %v", samples, expected)
+	}
+	if &samples[2] != &e.Records[1].Signals[1].Samples[0] {
+		t.Error("Samples should share the memory of the records")
+	}
+
+	e.Records[1].Signals[1].Samples[0] = 42
+	expected[2] = 42
+	if samples := e.Samples(1); !reflect.DeepEqual(samples, expected) || &samples[2] != &e.Records[1].Signals[1].Samples[0] {
+		t.Errorf("%v should be equal to %v", samples, expected)
+	}
+
+	// Replaced data records are copied.
+	e.Records = append([]Record(nil), records...)
+	records[1].Signals[1].Samples[0] = 43
+	expected[2] = 43
+	if samples := e.Samples(1); !reflect.DeepEqual(samples, expected) || &samples[2] == &records[1].Signals[1].Samples[0] {
+		t.Errorf("%v should be equal to %v", samples, expected)
+	}
+}
+
+func TestDecodeFilter(t *testing.T) {
+	data, records := testFile(3)
+	for _, filter := range []SignalFilter{SelectLabels("Resp", "ECG"), SelectIndices(1)} {
+		d := NewDecoder(bytes.NewReader(data))
+		d.Filter = filter
+		e, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Header.NumSignals != 1 || e.Header.HeaderSize != 512 || len(e.Header.Signals) != 1 || e.Header.Signals[0].Label != "Resp" {
+			t.Errorf("Unexpected header %+v", e.Header)
+		}
+		for i := range records {
+			if !reflect.DeepEqual(e.Records[i].Signals, records[i].Signals[1:]) {
+				t.Errorf("%v should be equal to %v", e.Records[i].Signals, records[i].Signals[1:])
+			}
+		}
+	}
+}
+
+func TestDecodeRecordAllocations(t *testing.T) {
+	data, _ := testFile(1)
+	header, err := readHeader(bytes.NewReader(data), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layout := newRecordLayout(header, []int{1})
+	data = data[header.HeaderSize:]
+	// The signals of the data record, and their samples.
+	if allocs := testing.AllocsPerRun(10, func() { layout.decode(data, &Record{}) }); allocs > 2 {
+		t.Errorf("Decoding a data record allocates %v times, should be 2", allocs)
+	}
+}
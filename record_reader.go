@@ -0,0 +1,106 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"io"
+)
+
+// A RecordReader reads the data records of an EDF file one at a time, so that
+// files of any size can be processed in constant memory.
+//
+//	rr, err := edf.NewRecordReader(r)
+//	...
+//	for rr.NextRecord() {
+//		record := rr.Record()
+//		...
+//	}
+//	if err := rr.Err(); err != nil {
+//		...
+//	}
+type RecordReader struct {
+	r      *bufio.Reader
+	header *Header
+	next   uint32
+	record *Record
+	layout *recordLayout
+	data   []byte
+	err    error
+}
+
+// NewRecordReader reads the header of the EDF file from r and returns a reader
+// positioned on its first data record. Compressed input is decompressed as by
+// Decoder, and the header is checked against DefaultLimits.
+func NewRecordReader(r io.Reader) (*RecordReader, error) {
+	input, _, err := decompress(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	header, err := readHeader(input, &DefaultLimits)
+	if err != nil {
+		return nil, err
+	}
+	return &RecordReader{r: input, header: header, layout: newRecordLayout(header, nil), data: make([]byte, header.RecordSize())}, nil
+}
+
+// Header returns the header of the EDF file.
+func (rr *RecordReader) Header() *Header {
+	return rr.header
+}
+
+// NextRecord reads the next data record, which will then be available through
+// Record. It returns false when there are no more records, either by reaching
+// the end of the file or on error. When the header does not declare the number
+// of data records, records are read until the end of the input.
+func (rr *RecordReader) NextRecord() bool {
+	if rr.err != nil {
+		rr.record = nil
+		return false
+	}
+	if rr.header.NumDataRecords == UnknownNumDataRecords {
+		if atEOF(rr.r) {
+			rr.record = nil
+			return false
+		}
+	} else if rr.next >= rr.header.NumDataRecords {
+		rr.record = nil
+		return false
+	}
+	record := &Record{}
+	if err := readRecord(rr.r, rr.layout, rr.data, record); err != nil {
+		rr.err = recordError(rr.header, int(rr.next), err)
+		rr.record = nil
+		return false
+	}
+	rr.record = record
+	rr.next++
+	return true
+}
+
+// Record returns the data record read by the last call to NextRecord.
+func (rr *RecordReader) Record() *Record {
+	return rr.record
+}
+
+// Index returns the index in the file of the data record returned by Record.
+func (rr *RecordReader) Index() int {
+	return int(rr.next) - 1
+}
+
+// Err returns the first error encountered by the RecordReader.
+func (rr *RecordReader) Err() error {
+	return rr.err
+}
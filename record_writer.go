@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+// Offset of the number of data records field in the header.
+const numDataRecordsOffset = 236
+
+// A RecordWriter writes the data records of an EDF file as they become
+// available, for instance during a live acquisition. The number of data records
+// is written as -1 in the header until the writer is closed.
+type RecordWriter struct {
+	w      io.WriteSeeker
+	start  int64
+	output *bufio.Writer
+	header *Header
+	count  uint32
+	closed bool
+}
+
+// NewRecordWriter writes the header to w, at its current offset, and returns a
+// writer for the data records. The NumDataRecords field of the header is
+// ignored.
+func NewRecordWriter(w io.WriteSeeker, header *Header) (*RecordWriter, error) {
+	start, err := w.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, err
+	}
+	h := *header
+	h.NumDataRecords = UnknownNumDataRecords
+	output := bufio.NewWriter(w)
+	if err := writeHeader(output, &h); err != nil {
+		return nil, err
+	}
+	return &RecordWriter{w: w, start: start, output: output, header: &h}, nil
+}
+
+// WriteRecord appends a data record to the file.
+func (rw *RecordWriter) WriteRecord(record *Record) error {
+	if rw.closed {
+		return errors.New("Writing to a closed RecordWriter")
+	}
+	if err := writeRecord(rw.output, rw.header, record); err != nil {
+		return err
+	}
+	rw.count++
+	return nil
+}
+
+// Count returns the number of data records written so far.
+func (rw *RecordWriter) Count() int {
+	return int(rw.count)
+}
+
+// Close flushes the data records and patches the number of data records in the
+// header. It does not close the underlying writer.
+func (rw *RecordWriter) Close() error {
+	if rw.closed {
+		return nil
+	}
+	rw.closed = true
+	if err := rw.output.Flush(); err != nil {
+		return err
+	}
+	if _, err := rw.w.Seek(rw.start+numDataRecordsOffset, io.SeekStart); err != nil {
+		return err
+	}
+	hw := &headerWriter{w: rw.w}
+	hw.write("NumDataRecords", formatNumDataRecords(rw.count), 8)
+	if hw.err != nil {
+		return hw.err
+	}
+	_, err := rw.w.Seek(0, io.SeekEnd)
+	return err
+}
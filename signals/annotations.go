@@ -20,6 +20,8 @@ import (
 	"io"
 	"strconv"
 	"time"
+
+	"github.com/google/edf"
 )
 
 type timestamp struct {
@@ -71,14 +73,8 @@ func newAnnotationSignal(baseSignal *edfSignal) (AnnotationSignal, error) {
 	for _, record := range records {
 		tsa := timeStampedAnnotation{timestamp{baseSignal.StartTime(), 0, 0}, []string{}}
 		signal := record.Signals[baseSignal.signalIndex]
-		// Extract bytes from 16-bit integers.
-		buffer := new(bytes.Buffer)
-		for _, biChar := range signal.Samples {
-			buffer.WriteByte(byte(biChar & 0xFF))
-			buffer.WriteByte(byte(biChar >> 8))
-		}
 		// Zero bytes don't count.
-		rawAnnotations := bytes.Split(bytes.Replace(buffer.Bytes(), []byte{'\x00'}, []byte{}, -1), []byte{'\x14'})
+		rawAnnotations := bytes.Split(bytes.Replace(edf.AnnotationBytes(baseSignal.edf.Header, &signal), []byte{'\x00'}, []byte{}, -1), []byte{'\x14'})
 		realAnnotations := [][]byte{}
 		for _, annotation := range rawAnnotations {
 			if len(annotation) != 0 {
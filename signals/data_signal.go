@@ -14,7 +14,10 @@
 
 package signals
 
-import "time"
+import (
+	"math"
+	"time"
+)
 
 type dataSignal struct {
 	Signal
@@ -34,14 +37,19 @@ func (s *dataSignal) SamplingRate() time.Duration {
 	return time.Duration(s.e.edf.Header.DurationDataRecords/float32(s.Definition().SamplesRecord)) * time.Second
 }
 
-// Returns the recording data, in physical units.
+// Returns the recording data, in physical units. Samples in the gaps of
+// discontinuous recordings are NaN.
 func (s *dataSignal) Recording(start, end time.Time) ([]float64, error) {
-	r, err := getSignalData(s.e.edf, s.e.signalIndex, start, end)
+	r, recorded, err := getSignalData(s.e, start, end)
 	if err != nil {
 		return nil, err
 	}
 	result := make([]float64, len(r))
 	for i, dataPoint := range r {
+		if recorded != nil && !recorded[i] {
+			result[i] = math.NaN()
+			continue
+		}
 		result[i] = s.e.a*float64(dataPoint) + s.e.b
 	}
 	return result, nil
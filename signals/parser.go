@@ -16,7 +16,8 @@ package signals
 
 import (
 	"fmt"
-	"strconv"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/google/edf"
@@ -24,13 +25,17 @@ import (
 
 // GetSignals return the signals from an EDF dataset.
 func GetSignals(e *edf.Edf) ([]Signal, error) {
+	onsets, err := e.RecordOnsets()
+	if err != nil {
+		return nil, err
+	}
 	signals := make([]Signal, e.Header.NumSignals)
 	for i := range e.Header.Signals {
-		signal, err := newEdfSignal(e, i)
+		signal, err := newEdfSignal(e, onsets, i)
 		if err != nil {
 			return nil, err
 		}
-		if e.Header.Signals[i].Label == "EDF Annotations" {
+		if e.Header.Signals[i].IsAnnotation() {
 			signals[i], err = newAnnotationSignal(signal)
 			if err != nil {
 				return nil, err
@@ -43,51 +48,38 @@ func GetSignals(e *edf.Edf) ([]Signal, error) {
 }
 
 type edfSignal struct {
-	edf         *edf.Edf
+	edf *edf.Edf
+	// start of each data record, in seconds since the start of the recording
+	onsets      []float64
 	startTime   time.Time
 	endTime     time.Time
 	signalIndex int
+	// samples of the signal across all records, see samples()
+	contiguous []int32
 
 	// digital to physical conversion parameters
 	a float64
 	b float64
 }
 
-func newEdfSignal(e *edf.Edf, signalIndex int) (*edfSignal, error) {
+func newEdfSignal(e *edf.Edf, onsets []float64, signalIndex int) (*edfSignal, error) {
 	s := new(edfSignal)
 	s.edf = e
+	s.onsets = onsets
 	s.signalIndex = signalIndex
 	start, err := getStartTime(e.Header)
 	if err != nil {
 		return nil, err
 	}
 	s.startTime = start
-	end, err := getEndTime(e.Header)
-	if err != nil {
-		return nil, err
-	}
-	s.endTime = end
+	s.endTime = getEndTime(e.Header, start, onsets)
 
 	def := &s.edf.Header.Signals[signalIndex]
-	physMin, err := strconv.ParseFloat(def.PhysicalMinimum, 32)
-	if err != nil {
-		return nil, err
-	}
-	physMax, err := strconv.ParseFloat(def.PhysicalMaximum, 32)
-	if err != nil {
-		return nil, err
-	}
-	digiMin, err := strconv.ParseFloat(def.DigitalMinimum, 32)
-	if err != nil {
-		return nil, err
-	}
-	digiMax, err := strconv.ParseFloat(def.DigitalMaximum, 32)
-	if err != nil {
-		return nil, err
+	if def.DigiMin == def.DigiMax {
+		return nil, fmt.Errorf("Signal %d has a degenerate digital range [%d, %d]", signalIndex, def.DigiMin, def.DigiMax)
 	}
-
-	s.a = (physMax - physMin) / (digiMax - digiMin)
-	s.b = physMin - s.a*digiMin
+	s.a = def.Gain()
+	s.b = def.Offset()
 
 	return s, nil
 }
@@ -111,68 +103,84 @@ func (s *edfSignal) Definition() *edf.SignalDefinition {
 	return &s.edf.Header.Signals[s.signalIndex]
 }
 
+// samples returns the samples of the signal across all records, which only
+// requires a copy if the EDF file does not already store them contiguously.
+func (s *edfSignal) samples() []int32 {
+	if s.contiguous == nil {
+		s.contiguous = s.edf.Samples(s.signalIndex)
+	}
+	return s.contiguous
+}
+
 // getStartTime returns the starting date and time of the recording
 func getStartTime(h *edf.Header) (time.Time, error) {
-	return time.Parse("02.01.06 15.04.05", h.StartDate+" "+h.StartTime)
+	return h.Start()
 }
 
-// getEndTime returns the end date and time of the recording
-func getEndTime(h *edf.Header) (time.Time, error) {
-	start, err := getStartTime(h)
-	if err != nil {
-		return start, err
+// getEndTime returns the end date and time of the recording, which is the end
+// of its last data record.
+func getEndTime(h *edf.Header, start time.Time, onsets []float64) time.Time {
+	if len(onsets) == 0 {
+		return start
 	}
-	end := start.Add(
-		time.Duration(
-			float32(h.NumDataRecords)*h.DurationDataRecords) * time.Second)
-	return end, nil
+	end := onsets[len(onsets)-1] + float64(h.DurationDataRecords)
+	return start.Add(time.Duration(end * float64(time.Second)))
 }
 
-// getSignalData returns the signal samples between the specified times.
-func getSignalData(e *edf.Edf, signalIndex int, start, end time.Time) ([]int16, error) {
-	recordingStart, err := getStartTime(e.Header)
-	if err != nil {
-		return nil, err
+// getSignalData returns the signal samples between the specified times, along
+// with whether each sample was recorded. Samples falling in the gaps between
+// the data records of discontinuous recordings are not. For continuous
+// recordings, the samples are a slice of the signal and recorded is nil.
+func getSignalData(s *edfSignal, start, end time.Time) ([]int32, []bool, error) {
+	if s.startTime.After(start) {
+		return nil, nil, fmt.Errorf("Requesting data before the recording")
 	}
-	if recordingStart.After(start) {
-		return nil, fmt.Errorf("Requesting data before the recording")
+	if s.endTime.Before(end) {
+		return nil, nil, fmt.Errorf("Requesting data after the recording")
 	}
-
-	recordingEnd, err := getEndTime(e.Header)
-	if err != nil {
-		return nil, err
-	}
-	if recordingEnd.Before(end) {
-		return nil, fmt.Errorf("Requesting data after the recording")
+	if end.Before(start) {
+		return nil, nil, fmt.Errorf("Requesting data ending before its start")
 	}
 
-	durationSample := float64(e.Header.DurationDataRecords) / float64(e.Header.Signals[signalIndex].SamplesRecord)
+	h := s.edf.Header
+	samplesRecord := int(h.Signals[s.signalIndex].SamplesRecord)
+	durationRecord := float64(h.DurationDataRecords)
+	durationSample := durationRecord / float64(samplesRecord)
 
-	skipStart := start.Sub(recordingStart)
-	startRecord := uint32(
-		skipStart.Seconds() / float64(e.Header.DurationDataRecords))
-	startSample := uint32(
-		(skipStart.Seconds() - (float64(startRecord) * float64(e.Header.DurationDataRecords))) / (durationSample))
-
-	endRecord := uint32(
-		end.Sub(recordingStart).Seconds() / float64(e.Header.DurationDataRecords))
-	endSample := uint32((end.Sub(recordingStart).Seconds() - (float64(endRecord) * float64(e.Header.DurationDataRecords))) / (durationSample))
+	// Index of the sample at t on a continuous timeline.
+	sampleIndex := func(t time.Time) int {
+		offset := t.Sub(s.startTime).Seconds()
+		record := int(offset / durationRecord)
+		return record*samplesRecord + int((offset-float64(record)*durationRecord)/durationSample)
+	}
+	first := sampleIndex(start)
+	last := sampleIndex(end)
+	samples := s.samples()
 
-	numSamples := e.Header.Signals[signalIndex].SamplesRecord*(endRecord-startRecord-1) + endSample + (e.Header.Signals[signalIndex].SamplesRecord - startSample)
+	if !h.IsDiscontinuous() {
+		if last > len(samples) {
+			last = len(samples)
+		}
+		return samples[first:last], nil, nil
+	}
 
-	result := make([]int16, numSamples)
-	s := 0
-	for i := startRecord; i <= endRecord; i++ {
-		for j := uint32(0); j < e.Header.Signals[signalIndex].SamplesRecord; j++ {
-			if i == startRecord && j == 0 {
-				j = startSample
-			}
-			if i == endRecord && j >= endSample {
-				break
+	result := make([]int32, last-first)
+	recorded := make([]bool, last-first)
+	// Skip the records ending before the first sample.
+	i := sort.Search(len(s.onsets), func(i int) bool {
+		return s.onsets[i]+durationRecord > float64(first)*durationSample
+	})
+	for ; i < len(s.onsets); i++ {
+		base := int(math.Round(s.onsets[i] / durationSample))
+		if base >= last {
+			break
+		}
+		for j, sample := range samples[i*samplesRecord : (i+1)*samplesRecord] {
+			if k := base + j - first; k >= 0 && k < len(result) {
+				result[k] = sample
+				recorded[k] = true
 			}
-			result[s] = e.Records[i].Signals[signalIndex].Samples[j]
-			s++
 		}
 	}
-	return result, nil
+	return result, recorded, nil
 }
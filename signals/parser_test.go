@@ -0,0 +1,114 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signals
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/edf"
+)
+
+// annotationSamples packs the bytes of tal two per sample.
+func annotationSamples(tal string, numSamples int) []int32 {
+	data := make([]byte, 2*numSamples)
+	copy(data, tal)
+	samples := make([]int32, numSamples)
+	for i := range samples {
+		samples[i] = int32(data[2*i]) | int32(data[2*i+1])<<8
+	}
+	return samples
+}
+
+// testEdf returns an EDF+D file with 3 data records starting at 0, 1 and 3
+// seconds, and a data signal of 2 samples per record.
+func testEdf() *edf.Edf {
+	e := &edf.Edf{
+		Header: &edf.Header{
+			StartDate:           "02.01.17",
+			StartTime:           "10.20.30",
+			Reserved:            "EDF+D",
+			NumDataRecords:      3,
+			DurationDataRecords: 1,
+			NumSignals:          2,
+			Signals: []edf.SignalDefinition{
+				{Label: "Resp", PhysMin: 0, PhysMax: 10, DigiMin: 0, DigiMax: 10, SamplesRecord: 2},
+				{Label: "EDF Annotations", PhysMin: -1, PhysMax: 1, DigiMin: -32768, DigiMax: 32767, SamplesRecord: 8},
+			},
+		},
+	}
+	for i, onset := range []string{"+0", "+1", "+3"} {
+		e.Records = append(e.Records, edf.Record{Signals: []edf.SignalRecord{
+			{Samples: []int32{int32(2 * i), int32(2*i + 1)}},
+			{Samples: annotationSamples(onset+"\x14\x14\x00", 8)},
+		}})
+	}
+	return e
+}
+
+func TestContinuousRecording(t *testing.T) {
+	e := testEdf()
+	e.Header.Reserved = "EDF+C"
+	signals, err := GetSignals(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signal := signals[0].(DataSignal)
+	recording, err := signal.Recording(signal.StartTime().Add(500*time.Millisecond), signal.EndTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []float64{1, 2, 3, 4, 5}; !reflect.DeepEqual(recording, expected) {
+		t.Errorf("%v should be equal to %v", recording, expected)
+	}
+	if _, err := signal.Recording(signal.EndTime(), signal.StartTime()); err == nil {
+		t.Error("Requesting data ending before its start should fail")
+	}
+}
+
+func TestDiscontinuousRecording(t *testing.T) {
+	signals, err := GetSignals(testEdf())
+	if err != nil {
+		t.Fatal(err)
+	}
+	signal := signals[0].(DataSignal)
+	if d := signal.EndTime().Sub(signal.StartTime()); d != 4*time.Second {
+		t.Errorf("Recording lasts %v, should be 4s", d)
+	}
+	recording, err := signal.Recording(signal.StartTime(), signal.EndTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []float64{0, 1, 2, 3, math.NaN(), math.NaN(), 4, 5}
+	if len(recording) != len(expected) {
+		t.Fatalf("%v should be equal to %v", recording, expected)
+	}
+	for i := range expected {
+		if recording[i] != expected[i] && !(math.IsNaN(recording[i]) && math.IsNaN(expected[i])) {
+			t.Errorf("%v should be equal to %v", recording, expected)
+			break
+		}
+	}
+
+	recording, err = signal.Recording(signal.StartTime().Add(1500*time.Millisecond), signal.StartTime().Add(3500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recording) != 4 || recording[0] != 3 || !math.IsNaN(recording[1]) || recording[3] != 4 {
+		t.Errorf("Unexpected recording %v", recording)
+	}
+}
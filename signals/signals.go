@@ -44,7 +44,8 @@ type DataSignal interface {
 	// SamplingRate returns the time between two recording samples of this signal.
 	SamplingRate() time.Duration
 
-	// Recording returns the recording data, in physical units.
+	// Recording returns the recording data, in physical units. Samples falling
+	// in the gaps of discontinuous (EDF+D) recordings are NaN.
 	Recording(start, end time.Time) ([]float64, error)
 }
 
@@ -18,6 +18,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"os"
 
 	"github.com/google/edf"
 	"github.com/google/edf/signals"
@@ -30,25 +31,43 @@ var (
 )
 
 func main() {
-	flag.Parse()
-	edfFile, err := edf.ReadEDF(*input)
-	if err != nil {
-		panic(err)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "patch":
+			patch(os.Args[2:])
+			return
+		case "diff":
+			diff(os.Args[2:])
+			return
+		case "merge":
+			merge(os.Args[2:])
+			return
+		}
 	}
 
+	flag.Parse()
 	if *signalLabel == "" && !*annotationLabel {
-		for _, signal := range edfFile.Header.Signals {
+		header, err := edf.ReadHeader(*input)
+		if err != nil {
+			panic(err)
+		}
+		for _, signal := range header.Signals {
 			fmt.Printf("Signal: '%s'\n", signal.Label)
 		}
 		return
 	}
 
+	edfFile, err := edf.ReadEDF(*input)
+	if err != nil {
+		panic(err)
+	}
+
 	edfSignals, err := signals.GetSignals(edfFile)
 	if err != nil {
 		panic(err)
 	}
 	for _, signal := range edfSignals {
-		if signal.Label() != *signalLabel && (!*annotationLabel || signal.Label() != "EDF Annotations") {
+		if signal.Label() != *signalLabel && (!*annotationLabel || !signal.Definition().IsAnnotation()) {
 			continue
 		}
 		fmt.Printf("Signal: %s\n", signal.Label())
@@ -69,3 +88,125 @@ func main() {
 		}
 	}
 }
+
+// patch rewrites a text field of the header of an EDF file in place, e.g.
+//
+//	edf-tool patch -input recording.edf -signal 1 -field Label -value "Resp Thorax"
+func patch(args []string) {
+	flags := flag.NewFlagSet("patch", flag.ExitOnError)
+	input := flags.String("input", "", "input")
+	signal := flags.Int("signal", -1, "index of the signal, -1 for a field of the file")
+	field := flags.String("field", "", "field")
+	value := flags.String("value", "", "value")
+	flags.Parse(args)
+
+	header, err := edf.ReadHeader(*input)
+	if err != nil {
+		panic(err)
+	}
+	var target *string
+	if *signal < 0 {
+		target = headerField(header, *field)
+	} else if *signal < len(header.Signals) {
+		target = signalField(&header.Signals[*signal], *field)
+	} else {
+		panic(fmt.Sprintf("No signal %d", *signal))
+	}
+	if target == nil {
+		panic(fmt.Sprintf("Cannot patch field %s", *field))
+	}
+	*target = *value
+	if err := edf.PatchHeader(*input, header); err != nil {
+		panic(err)
+	}
+}
+
+// Returns the text field of the header with the given name.
+func headerField(header *edf.Header, field string) *string {
+	switch field {
+	case "PatiendID":
+		return &header.PatiendID
+	case "RecordingID":
+		return &header.RecordingID
+	case "StartDate":
+		return &header.StartDate
+	case "StartTime":
+		return &header.StartTime
+	case "Reserved":
+		return &header.Reserved
+	}
+	return nil
+}
+
+// Returns the text field of the signal definition with the given name.
+func signalField(signal *edf.SignalDefinition, field string) *string {
+	switch field {
+	case "Label":
+		return &signal.Label
+	case "TransducerType":
+		return &signal.TransducerType
+	case "PhysicalDimension":
+		return &signal.PhysicalDimension
+	case "Prefiltering":
+		return &signal.Prefiltering
+	case "Reserved":
+		return &signal.Reserved
+	}
+	return nil
+}
+
+// diff prints the differences between two EDF files, exiting with status 1 if
+// there are any, e.g.
+//
+//	edf-tool diff original.edf converted.edf
+func diff(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		panic("diff takes two EDF files")
+	}
+
+	a, err := edf.ReadEDF(flags.Arg(0))
+	if err != nil {
+		panic(err)
+	}
+	b, err := edf.ReadEDF(flags.Arg(1))
+	if err != nil {
+		panic(err)
+	}
+	differences, err := edf.Diff(a, b)
+	if err != nil {
+		panic(err)
+	}
+	for _, d := range differences {
+		fmt.Println(d)
+	}
+	if len(differences) > 0 {
+		os.Exit(1)
+	}
+}
+
+// merge concatenates consecutive EDF files into a single one, e.g.
+//
+//	edf-tool merge -output night.edf 22h.edf 23h.edf 00h.edf
+func merge(args []string) {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("output", "", "output")
+	flags.Parse(args)
+
+	var files []*edf.Edf
+	for _, filename := range flags.Args() {
+		edfFile, err := edf.ReadEDF(filename)
+		if err != nil {
+			panic(err)
+		}
+		files = append(files, edfFile)
+	}
+	merged, err := edf.Merge(files...)
+	if err != nil {
+		panic(err)
+	}
+	if err := edf.WriteEDF(*output, merged); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,267 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Severity is the severity of a Problem.
+type Severity int
+
+const (
+	// SeverityWarning is a deviation from the specification that readers can
+	// usually cope with.
+	SeverityWarning Severity = iota
+	// SeverityError is a violation of the specification that prevents reading
+	// the file correctly.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Problem is a deviation of an EDF file from the EDF or EDF+ specification.
+type Problem struct {
+	// Field is the name of the Header or SignalDefinition field concerned.
+	Field string
+	// Signal is the index of the signal concerned, or -1 for fields that are
+	// not part of a signal definition.
+	Signal   int
+	Severity Severity
+	Message  string
+}
+
+func (p Problem) String() string {
+	if p.Signal < 0 {
+		return fmt.Sprintf("%s: %s: %s", p.Severity, p.Field, p.Message)
+	}
+	return fmt.Sprintf("%s: %s of signal %d: %s", p.Severity, p.Field, p.Signal, p.Message)
+}
+
+// validator collects the problems found in a header.
+type validator struct {
+	problems []Problem
+}
+
+func (v *validator) report(field string, signal int, severity Severity, format string, args ...interface{}) {
+	v.problems = append(v.problems, Problem{field, signal, severity, fmt.Sprintf(format, args...)})
+}
+
+// Reports non printable ASCII characters in a text field.
+func (v *validator) ascii(field string, signal int, value string) {
+	for i := 0; i < len(value); i++ {
+		if value[i] < 32 || value[i] > 126 {
+			v.report(field, signal, SeverityError, "Invalid byte %#x at position %d", value[i], i)
+			return
+		}
+	}
+}
+
+// Reports digital values written as decimals, which are accepted when reading.
+func (v *validator) integer(field string, signal int, value string) {
+	if _, err := strconv.ParseInt(value, 10, 32); value != "" && err != nil {
+		v.report(field, signal, SeverityWarning, "%q is not an integer", value)
+	}
+}
+
+// Returns whether the raw calibration values are unset, the parsed ones being
+// used instead, or can be parsed. The range of values that cannot be parsed is
+// not checked, as their parse errors are reported already.
+func parsed(parse func(string) error, values ...string) bool {
+	for _, value := range values {
+		if value != "" && parse(value) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func parseFloat(value string) error {
+	_, err := strconv.ParseFloat(value, 64)
+	return err
+}
+
+func parseInt(value string) error {
+	_, err := parseDigital(value)
+	return err
+}
+
+// Reports values that don't match a dd.mm.yy or hh.mm.ss pattern.
+func (v *validator) dotted(field string, value string) bool {
+	valid := len(value) == 8 && value[2] == '.' && value[5] == '.'
+	for _, i := range []int{0, 1, 3, 4, 6, 7} {
+		valid = valid && value[i] >= '0' && value[i] <= '9'
+	}
+	if !valid {
+		v.report(field, -1, SeverityError, "%q does not use the dd.dd.dd format", value)
+	}
+	return valid
+}
+
+// Validate checks a header against the EDF and EDF+ specifications, and returns
+// all the problems found.
+func Validate(h *Header) []Problem {
+	v := &validator{}
+
+	if h.Version != "0" && !h.IsBDF() {
+		v.report("Version", -1, SeverityError, "Unknown version %q", h.Version)
+	}
+	v.ascii("PatiendID", -1, h.PatiendID)
+	v.ascii("RecordingID", -1, h.RecordingID)
+	validDate := v.dotted("StartDate", h.StartDate)
+	validTime := v.dotted("StartTime", h.StartTime)
+	if _, err := h.Start(); validDate && validTime && err != nil {
+		v.report("StartDate", -1, SeverityError, "Invalid start date and time: %v", err)
+	}
+	if expected := 256 * (len(h.Signals) + 1); int(h.HeaderSize) != expected {
+		v.report("HeaderSize", -1, SeverityError, "%d should be %d for %d signals", h.HeaderSize, expected, len(h.Signals))
+	}
+	v.ascii("Reserved", -1, h.Reserved)
+	if h.DurationDataRecords < 0 {
+		v.report("DurationDataRecords", -1, SeverityError, "Negative duration %v", h.DurationDataRecords)
+	}
+	if int(h.NumSignals) != len(h.Signals) {
+		v.report("NumSignals", -1, SeverityError, "%d signals declared, %d defined", h.NumSignals, len(h.Signals))
+	}
+
+	if strings.HasPrefix(h.Reserved, "EDF+") || strings.HasPrefix(h.Reserved, "BDF+") {
+		if h.Reserved[4:] != "C" && h.Reserved[4:] != "D" {
+			v.report("Reserved", -1, SeverityError, "Unknown EDF+ file type %q", h.Reserved)
+		}
+		if h.AnnotationSignal() < 0 {
+			v.report("Signals", -1, SeverityError, "EDF+ file without annotation signal")
+		}
+		if _, err := h.PatientInfo(); err != nil {
+			v.report("PatiendID", -1, SeverityWarning, "%v", err)
+		}
+		if _, err := h.RecordingInfo(); err != nil {
+			v.report("RecordingID", -1, SeverityWarning, "%v", err)
+		}
+	}
+
+	maxDigital := int32(1)<<uint(8*h.SampleSize()-1) - 1
+	minDigital := -maxDigital - 1
+	for i := range h.Signals {
+		s := &h.Signals[i]
+		v.ascii("Label", i, s.Label)
+		v.ascii("TransducerType", i, s.TransducerType)
+		v.ascii("PhysicalDimension", i, s.PhysicalDimension)
+		v.ascii("Prefiltering", i, s.Prefiltering)
+		v.ascii("Reserved", i, s.Reserved)
+
+		if parsed(parseFloat, s.PhysicalMinimum, s.PhysicalMaximum) && s.PhysMin == s.PhysMax {
+			v.report("PhysicalMaximum", i, SeverityError, "Physical minimum and maximum are both %v", s.PhysMin)
+		}
+		v.integer("DigitalMinimum", i, s.DigitalMinimum)
+		v.integer("DigitalMaximum", i, s.DigitalMaximum)
+		if s.DigiMin < minDigital || s.DigiMin > maxDigital {
+			v.report("DigitalMinimum", i, SeverityError, "%d is out of range [%d, %d]", s.DigiMin, minDigital, maxDigital)
+		}
+		if s.DigiMax < minDigital || s.DigiMax > maxDigital {
+			v.report("DigitalMaximum", i, SeverityError, "%d is out of range [%d, %d]", s.DigiMax, minDigital, maxDigital)
+		}
+		if parsed(parseInt, s.DigitalMinimum, s.DigitalMaximum) && s.DigiMin >= s.DigiMax {
+			v.report("DigitalMaximum", i, SeverityError, "Digital minimum %d is not lower than digital maximum %d", s.DigiMin, s.DigiMax)
+		}
+		if s.SamplesRecord == 0 {
+			v.report("SamplesRecord", i, SeverityWarning, "Signal without samples")
+		}
+	}
+	return v.problems
+}
+
+// ValidateFile checks an EDF file against the EDF and EDF+ specifications, and
+// returns all the problems found in its header, including fields that cannot be
+// parsed, and a size of the data records inconsistent with the header. The size
+// of compressed files is not checked. The returned error is only set when the
+// file cannot be read.
+func ValidateFile(filename string) ([]Problem, error) {
+	fileInput, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fileInput.Close()
+	info, err := fileInput.Stat()
+	if err != nil {
+		return nil, err
+	}
+	input, compressed, err := decompress(bufio.NewReader(fileInput))
+	if err != nil {
+		return nil, err
+	}
+	if compressed {
+		return validate(input, -1)
+	}
+	return validate(input, info.Size())
+}
+
+// Validates the file read from input, whose size is checked unless negative.
+func validate(input io.Reader, size int64) ([]Problem, error) {
+	raw, err := readRawHeader(input, nil)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return []Problem{{"HeaderSize", -1, SeverityError, "File is shorter than its header"}}, nil
+	} else if pe, ok := err.(*ParseError); ok && pe.Field == "NumSignals" {
+		return []Problem{{pe.Field, pe.Signal, SeverityError, pe.Err.Error()}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	parser := &headerParser{}
+	header := parser.parse(raw)
+	var problems []Problem
+	failed := map[Problem]bool{}
+	for _, pe := range parser.errs {
+		problems = append(problems, Problem{pe.Field, pe.Signal, SeverityError, pe.Err.Error()})
+		failed[Problem{Field: pe.Field, Signal: pe.Signal}] = true
+	}
+	// Fields that could not be parsed are not checked further.
+	for _, p := range Validate(header) {
+		if !failed[Problem{Field: p.Field, Signal: p.Signal}] {
+			problems = append(problems, p)
+		}
+	}
+
+	if size < 0 || failed[Problem{Field: "NumDataRecords", Signal: -1}] || failed[Problem{Field: "HeaderSize", Signal: -1}] {
+		return problems, nil
+	}
+	for i := range header.Signals {
+		if failed[Problem{Field: "SamplesRecord", Signal: i}] {
+			return problems, nil
+		}
+	}
+	dataSize := size - int64(header.HeaderSize)
+	recordSize := header.RecordSize()
+	if header.NumDataRecords == UnknownNumDataRecords {
+		if recordSize > 0 && dataSize%recordSize != 0 {
+			problems = append(problems, Problem{"NumDataRecords", -1, SeverityError,
+				fmt.Sprintf("%d bytes of data records is not a multiple of the record size %d", dataSize, recordSize)})
+		}
+	} else if expected := header.DataSize(); dataSize != expected {
+		problems = append(problems, Problem{"NumDataRecords", -1, SeverityError,
+			fmt.Sprintf("%d bytes of data records, expected %d for %d records of %d bytes", dataSize, expected, header.NumDataRecords, recordSize)})
+	}
+	return problems, nil
+}
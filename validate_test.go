@@ -0,0 +1,83 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	data, _ := testFile(2)
+	problems, err := validate(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Unexpected problems %v", problems)
+	}
+
+	copy(data[168:], "02/01/17")
+	copy(data[184:], pad("512", 8))
+	// Label of the second signal.
+	copy(data[256+16:], "R\xe9sp")
+	// Digital minimum of the first signal.
+	copy(data[256+2*(16+80+8+8+8):], pad("40000", 8))
+	// Samples per record of the second signal.
+	copy(data[256+2*(16+80+8+8+8+8+8+80)+8:], pad("two", 8))
+	problems, err = validate(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Problem{
+		{"SamplesRecord", 1, SeverityError, `strconv.ParseUint: parsing "two": invalid syntax`},
+		{"StartDate", -1, SeverityError, `"02/01/17" does not use the dd.dd.dd format`},
+		{"HeaderSize", -1, SeverityError, "512 should be 768 for 2 signals"},
+		{"DigitalMinimum", 0, SeverityError, "40000 is out of range [-32768, 32767]"},
+		{"DigitalMaximum", 0, SeverityError, "Digital minimum 40000 is not lower than digital maximum 32767"},
+		{"Label", 1, SeverityError, "Invalid byte 0xe9 at position 1"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("%v should be equal to %v", problems, expected)
+	}
+
+	// Ranges are not checked when their bounds cannot be parsed.
+	data, _ = testFile(2)
+	copy(data[256+2*(16+80+8):], pad("abc", 8)+pad("abc", 8)+pad("0", 8))
+	problems, err = validate(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Problem{
+		{"PhysicalMinimum", 0, SeverityError, `strconv.ParseFloat: parsing "abc": invalid syntax`},
+		{"PhysicalMinimum", 1, SeverityError, `strconv.ParseFloat: parsing "abc": invalid syntax`},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("%v should be equal to %v", problems, expected)
+	}
+
+	data, _ = testFile(2)
+	problems, err = validate(bytes.NewReader(data), int64(len(data)-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = []Problem{
+		{"NumDataRecords", -1, SeverityError, "23 bytes of data records, expected 24 for 2 records of 12 bytes"},
+	}
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("%v should be equal to %v", problems, expected)
+	}
+}
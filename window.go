@@ -0,0 +1,126 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReadRange reads count consecutive data records, starting at the first-th
+// record of the file, as an EDF file of its own: its header declares count
+// data records and starts with the first one.
+//
+// Header start times have a resolution of one second. For EDF+ files, the
+// remaining fraction is kept in the time-keeping annotations, whose onsets are
+// made relative to the new start time. For EDF files, it is lost.
+func (ra *RandomAccessReader) ReadRange(first, count int) (*Edf, error) {
+	e, err := ra.readRange(first, count)
+	if err != nil {
+		return nil, err
+	}
+	header := *ra.header
+	header.NumDataRecords = uint32(count)
+	e.Header = &header
+	if count == 0 {
+		return e, nil
+	}
+	onset, err := ra.header.RecordOnset(first, &e.Records[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := e.shiftStart(int64(math.Floor(onset))); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// ReadTime reads the data records overlapping the time interval [start, end),
+// as an EDF file of its own. See ReadRange.
+func (ra *RandomAccessReader) ReadTime(start, end time.Time) (*Edf, error) {
+	if !start.Before(end) {
+		return nil, errors.New("Invalid start or end time")
+	}
+	recordingStart, err := ra.header.Start()
+	if err != nil {
+		return nil, err
+	}
+	from := start.Sub(recordingStart).Seconds()
+	to := end.Sub(recordingStart).Seconds()
+	duration := float64(ra.header.DurationDataRecords)
+
+	// Onsets of continuous files are computed, the ones of discontinuous files
+	// read from the records.
+	onset := func(i int) float64 {
+		if err != nil || !ra.header.IsDiscontinuous() {
+			return float64(i) * duration
+		}
+		var record *Record
+		var onset float64
+		if record, err = ra.ReadRecord(i); err == nil {
+			onset, err = ra.header.RecordOnset(i, record)
+		}
+		return onset
+	}
+	first := sort.Search(ra.NumRecords(), func(i int) bool { return onset(i)+duration > from })
+	last := sort.Search(ra.NumRecords(), func(i int) bool { return onset(i) >= to })
+	if err != nil {
+		return nil, err
+	}
+	if last < first {
+		last = first
+	}
+	return ra.ReadRange(first, last-first)
+}
+
+// Moves the start of the recording later by the given number of seconds,
+// keeping the onsets of EDF+ annotations unchanged.
+func (e *Edf) shiftStart(seconds int64) error {
+	if seconds == 0 {
+		return nil
+	}
+	h := e.Header
+	start, err := h.Start()
+	if err != nil {
+		return err
+	}
+	start = start.Add(time.Duration(seconds) * time.Second)
+	h.StartDate = start.Format("02.01.06")
+	h.StartTime = start.Format("15.04.05")
+	if info, err := h.RecordingInfo(); err == nil && !info.StartDate.IsZero() {
+		// Only replace the date subfield to keep the rest of the field as is.
+		date := strings.Fields(h.RecordingID)[1]
+		h.RecordingID = strings.Replace(h.RecordingID, date, formatIdentificationDate(start), 1)
+	}
+
+	if !h.isPlus() {
+		return nil
+	}
+	for s := range h.Signals {
+		if !h.Signals[s].IsAnnotation() {
+			continue
+		}
+		for i := range e.Records {
+			signal := &e.Records[i].Signals[s]
+			if err := setAnnotationBytes(h, signal, shiftOnsets(AnnotationBytes(h, signal), seconds)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEdfPlus returns an EDF+ file with a data signal of 2 samples per record
+// and an annotation signal, whose data records start at the given onsets.
+func testEdfPlus(t *testing.T, reserved string, onsets []string) *Edf {
+	e := &Edf{Header: &Header{
+		Version:             "0",
+		PatiendID:           "X X X X",
+		RecordingID:         "Startdate 02-JAN-2017 X X X",
+		StartDate:           "02.01.17",
+		StartTime:           "10.20.30",
+		HeaderSize:          768,
+		Reserved:            reserved,
+		NumDataRecords:      uint32(len(onsets)),
+		DurationDataRecords: 1,
+		NumSignals:          2,
+		Signals: []SignalDefinition{
+			{Label: "Resp", PhysMin: 0, PhysMax: 10, DigiMin: 0, DigiMax: 10, SamplesRecord: 2},
+			{Label: "EDF Annotations", PhysMin: -1, PhysMax: 1, DigiMin: -32768, DigiMax: 32767, SamplesRecord: 16},
+		},
+	}}
+	for i, onset := range onsets {
+		record := Record{Signals: []SignalRecord{
+			{Samples: []int32{int32(2 * i), int32(2*i + 1)}},
+			{Samples: make([]int32, 16)},
+		}}
+		tal := onset + "\x14\x14\x00" + onset + "\x15" + "0.5\x14Apnea\x14\x00"
+		if err := setAnnotationBytes(e.Header, &record.Signals[1], []byte(tal)); err != nil {
+			t.Fatal(err)
+		}
+		e.Records = append(e.Records, record)
+	}
+	return e
+}
+
+func TestReadTime(t *testing.T) {
+	data, records := testFile(5)
+	ra, err := NewRandomAccessReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, _ := ra.Header().Start()
+	e, err := ra.ReadTime(start.Add(1500*time.Millisecond), start.Add(3*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 2 || e.Header.StartTime != "10.20.31" || e.Header.RecordingID != "Startdate 02-JAN-2017 X X X" {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	if !reflect.DeepEqual(e.Records, records[1:3]) {
+		t.Errorf("%v should be equal to %v", e.Records, records[1:3])
+	}
+	if ra.Header().StartTime != "10.20.30" {
+		t.Error("The header of the file should not be modified")
+	}
+}
+
+func TestReadTimeDiscontinuous(t *testing.T) {
+	output := new(bytes.Buffer)
+	if err := Write(output, testEdfPlus(t, "EDF+D", []string{"+0", "+1", "+86400.5", "+86401.5"})); err != nil {
+		t.Fatal(err)
+	}
+	ra, err := NewRandomAccessReader(bytes.NewReader(output.Bytes()), int64(output.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, _ := ra.Header().Start()
+	e, err := ra.ReadTime(start.Add(24*time.Hour), start.Add(25*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Header.NumDataRecords != 2 || e.Header.StartDate != "03.01.17" || e.Header.StartTime != "10.20.30" || e.Header.RecordingID != "Startdate 03-JAN-2017 X X X" {
+		t.Errorf("Unexpected header %+v", e.Header)
+	}
+	onsets, err := e.RecordOnsets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(onsets, []float64{0.5, 1.5}) {
+		t.Errorf("Unexpected onsets %v", onsets)
+	}
+	if tal := string(AnnotationBytes(e.Header, &e.Records[1].Signals[1])); strings.TrimRight(tal, "\x00") != "+1.5\x14\x14\x00+1.5\x150.5\x14Apnea\x14" {
+		t.Errorf("Unexpected annotations %q", tal)
+	}
+}
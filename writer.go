@@ -0,0 +1,272 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WriteEDF writes an EDF file, gzip compressed if the name of the file ends
+// with ".gz".
+func WriteEDF(filename string, edf *Edf) error {
+	fileOutput, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	e := NewEncoder(fileOutput)
+	e.Gzip = strings.HasSuffix(filename, ".gz")
+	if err := e.Encode(edf); err != nil {
+		fileOutput.Close()
+		return err
+	}
+	return fileOutput.Close()
+}
+
+// Write writes an EDF file to w.
+func Write(w io.Writer, edf *Edf) error {
+	return NewEncoder(w).Encode(edf)
+}
+
+// PatchHeader overwrites the header of an existing EDF file with header,
+// leaving its data records untouched. The new header must define the same
+// number of signals, with the same number of samples per data record, as the
+// one of the file, and its fields must fit in their fixed widths. The file is
+// left unchanged if any of these conditions is not met.
+func PatchHeader(filename string, header *Header) error {
+	file, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if err := patchHeader(file, header); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func patchHeader(file *os.File, header *Header) error {
+	input, compressed, err := decompress(bufio.NewReader(file))
+	if err != nil {
+		return err
+	}
+	if compressed {
+		return errors.New("Cannot patch the header of a compressed file")
+	}
+	current, err := readHeader(input, nil)
+	if err != nil {
+		return err
+	}
+	if header.NumSignals != current.NumSignals || len(header.Signals) != len(current.Signals) {
+		return fmt.Errorf("Header declares %d signals, the file has %d", header.NumSignals, current.NumSignals)
+	}
+	if header.IsBDF() != current.IsBDF() {
+		return errors.New("Header and file differ in sample size")
+	}
+	for i := range header.Signals {
+		if header.Signals[i].SamplesRecord != current.Signals[i].SamplesRecord {
+			return fmt.Errorf("Header declares %d samples per record for signal %d, the file has %d",
+				header.Signals[i].SamplesRecord, i, current.Signals[i].SamplesRecord)
+		}
+	}
+
+	// The header is encoded before writing anything, so that a field not fitting
+	// does not leave the file half patched.
+	output := new(bytes.Buffer)
+	if err := writeHeader(output, header); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(output.Bytes(), 0)
+	return err
+}
+
+// An Encoder writes an EDF file to an output stream.
+type Encoder struct {
+	// Gzip enables the gzip compression of the output.
+	Gzip bool
+
+	w io.Writer
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes the header and all the data records of edf. The header size
+// written is computed from the number of signals, regardless of the HeaderSize
+// field of the header.
+func (e *Encoder) Encode(edf *Edf) error {
+	if edf.Header.NumDataRecords != UnknownNumDataRecords && int(edf.Header.NumDataRecords) != len(edf.Records) {
+		return fmt.Errorf("Header declares %d data records, found %d", edf.Header.NumDataRecords, len(edf.Records))
+	}
+	w := e.w
+	var compressor *gzip.Writer
+	if e.Gzip {
+		compressor = gzip.NewWriter(w)
+		w = compressor
+	}
+	output := bufio.NewWriter(w)
+	if err := writeHeader(output, edf.Header); err != nil {
+		return err
+	}
+	for i := range edf.Records {
+		if err := writeRecord(output, edf.Header, &edf.Records[i]); err != nil {
+			return err
+		}
+	}
+	if err := output.Flush(); err != nil {
+		return err
+	}
+	if compressor != nil {
+		return compressor.Close()
+	}
+	return nil
+}
+
+// headerWriter writes the fixed-width, space padded ASCII fields of an EDF
+// header, keeping the first error encountered.
+type headerWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (hw *headerWriter) write(name string, value string, width int) {
+	if hw.err != nil {
+		return
+	}
+	if len(value) > width {
+		hw.err = fmt.Errorf("Value %q of field %s does not fit in %d bytes", value, name, width)
+		return
+	}
+	_, hw.err = io.WriteString(hw.w, value+strings.Repeat(" ", width-len(value)))
+}
+
+func (hw *headerWriter) writeSignals(name string, signals []SignalDefinition, width int, value func(*SignalDefinition) string) {
+	for i := range signals {
+		hw.write(fmt.Sprintf("%s of signal %d", name, i), value(&signals[i]), width)
+	}
+}
+
+// Writes the header of the EDF+ file.
+func writeHeader(output io.Writer, header *Header) error {
+	if int(header.NumSignals) != len(header.Signals) {
+		return fmt.Errorf("Header declares %d signals, found %d definitions", header.NumSignals, len(header.Signals))
+	}
+	hw := &headerWriter{w: output}
+	hw.write("Version", header.Version, 8)
+	hw.write("PatiendID", header.PatiendID, 80)
+	hw.write("RecordingID", header.RecordingID, 80)
+	hw.write("StartDate", header.StartDate, 8)
+	hw.write("StartTime", header.StartTime, 8)
+	hw.write("HeaderSize", strconv.Itoa(256*(len(header.Signals)+1)), 8)
+	hw.write("Reserved", header.Reserved, 44)
+	hw.write("NumDataRecords", formatNumDataRecords(header.NumDataRecords), 8)
+	hw.write("DurationDataRecords", strconv.FormatFloat(float64(header.DurationDataRecords), 'f', -1, 32), 8)
+	hw.write("NumSignals", strconv.Itoa(len(header.Signals)), 4)
+
+	hw.writeSignals("Label", header.Signals, 16, func(s *SignalDefinition) string { return s.Label })
+	hw.writeSignals("TransducerType", header.Signals, 80, func(s *SignalDefinition) string { return s.TransducerType })
+	hw.writeSignals("PhysicalDimension", header.Signals, 8, func(s *SignalDefinition) string { return s.PhysicalDimension })
+	hw.writeSignals("PhysicalMinimum", header.Signals, 8, func(s *SignalDefinition) string { return formatFloat(s.PhysicalMinimum, s.PhysMin, 8) })
+	hw.writeSignals("PhysicalMaximum", header.Signals, 8, func(s *SignalDefinition) string { return formatFloat(s.PhysicalMaximum, s.PhysMax, 8) })
+	hw.writeSignals("DigitalMinimum", header.Signals, 8, func(s *SignalDefinition) string { return formatInt(s.DigitalMinimum, s.DigiMin) })
+	hw.writeSignals("DigitalMaximum", header.Signals, 8, func(s *SignalDefinition) string { return formatInt(s.DigitalMaximum, s.DigiMax) })
+	hw.writeSignals("Prefiltering", header.Signals, 80, func(s *SignalDefinition) string { return s.Prefiltering })
+	hw.writeSignals("SamplesRecord", header.Signals, 8, func(s *SignalDefinition) string { return strconv.FormatUint(uint64(s.SamplesRecord), 10) })
+	hw.writeSignals("Reserved", header.Signals, 32, func(s *SignalDefinition) string { return s.Reserved })
+	return hw.err
+}
+
+// Formats a physical value in at most width bytes, keeping its raw
+// representation if it is the one of the value.
+func formatFloat(raw string, value float64, width int) string {
+	if v, err := strconv.ParseFloat(raw, 64); err == nil && v == value {
+		return raw
+	}
+	s := strconv.FormatFloat(value, 'f', -1, 64)
+	for precision := width; len(s) > width && precision >= 0; precision-- {
+		s = strconv.FormatFloat(value, 'f', precision, 64)
+	}
+	return s
+}
+
+// Formats a digital value, keeping its raw representation if it is the one of
+// the value.
+func formatInt(raw string, value int32) string {
+	if v, err := parseDigital(raw); err == nil && v == value {
+		return raw
+	}
+	return strconv.FormatInt(int64(value), 10)
+}
+
+func formatNumDataRecords(n uint32) string {
+	if n == UnknownNumDataRecords {
+		return "-1"
+	}
+	return strconv.FormatUint(uint64(n), 10)
+}
+
+// Writes a single data record described by header.
+func writeRecord(output io.Writer, header *Header, record *Record) error {
+	if len(record.Signals) != len(header.Signals) {
+		return fmt.Errorf("Record has %d signals, header declares %d", len(record.Signals), len(header.Signals))
+	}
+	sampleSize := header.SampleSize()
+	data := make([]byte, header.RecordSize())
+	offset := 0
+	for s := range record.Signals {
+		samples := record.Signals[s].Samples
+		if len(samples) != int(header.Signals[s].SamplesRecord) {
+			return fmt.Errorf("Signal %d has %d samples, header declares %d", s, len(samples), header.Signals[s].SamplesRecord)
+		}
+		for _, sample := range samples {
+			if !encodeSample(data[offset:offset+sampleSize], sample) {
+				return fmt.Errorf("Sample %d of signal %d does not fit in %d bytes", sample, s, sampleSize)
+			}
+			offset += sampleSize
+		}
+	}
+	_, err := output.Write(data)
+	return err
+}
+
+// Encodes a sample as a little-endian, two's complement 16 or 24-bit integer,
+// depending on the size of data. Returns false if the sample is out of range.
+func encodeSample(data []byte, sample int32) bool {
+	if len(data) == 3 {
+		if sample < -1<<23 || sample >= 1<<23 {
+			return false
+		}
+		data[0] = byte(sample)
+		data[1] = byte(sample >> 8)
+		data[2] = byte(sample >> 16)
+		return true
+	}
+	if sample < math.MinInt16 || sample > math.MaxInt16 {
+		return false
+	}
+	binary.LittleEndian.PutUint16(data, uint16(sample))
+	return true
+}
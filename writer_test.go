@@ -0,0 +1,230 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package edf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data, _ := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := new(bytes.Buffer)
+	if err := Write(output, e); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(output.Bytes(), data) {
+		t.Errorf("Written file differs from the original:\n%q\n%q", output.Bytes(), data)
+	}
+
+	dir, err := ioutil.TempDir("", "edf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "test.edf")
+	if err := WriteEDF(filename, e); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := ReadEDF(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, e) {
+		t.Errorf("%+v should be equal to %+v", actual, e)
+	}
+}
+
+func TestWriteInvalid(t *testing.T) {
+	data, _ := testFile(1)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Header.Signals[0].Label = "A label that is too long"
+	if err := Write(ioutil.Discard, e); err == nil {
+		t.Error("Writing a label longer than 16 bytes should fail")
+	}
+	e.Header.Signals[0].Label = "EEG"
+	e.Records[0].Signals[1].Samples = e.Records[0].Signals[1].Samples[1:]
+	if err := Write(ioutil.Discard, e); err == nil {
+		t.Error("Writing a record with missing samples should fail")
+	}
+}
+
+func TestRecordWriter(t *testing.T) {
+	data, _ := testFile(3)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "edf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	// The header is patched relative to where it was written.
+	prefix := "Container header"
+	f.WriteString(prefix)
+
+	rw, err := NewRecordWriter(f, e.Header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range e.Records {
+		if err := rw.WriteRecord(&e.Records[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Count() != 3 {
+		t.Errorf("Count is %d, should be 3", rw.Count())
+	}
+	actual, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, append([]byte(prefix), data...)) {
+		t.Errorf("Written file differs from the original:\n%q\n%q", actual, data)
+	}
+}
+
+func TestPatchHeader(t *testing.T) {
+	data, records := testFile(3)
+	f, err := ioutil.TempFile("", "edf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.Write(data)
+	f.Close()
+
+	header, err := ReadHeader(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	header.PatiendID = "MCH-0234567 F 02-MAY-1951 Haagse_Harry"
+	header.Signals[1].Label = "Resp Thorax"
+	if err := PatchHeader(f.Name(), header); err != nil {
+		t.Fatal(err)
+	}
+	e, err := ReadEDF(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.Header, header) {
+		t.Errorf("%+v should be equal to %+v", e.Header, header)
+	}
+	if !reflect.DeepEqual(e.Records, records) {
+		t.Errorf("%v should be equal to %v", e.Records, records)
+	}
+
+	patched, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := []func(h *Header){
+		func(h *Header) { h.Signals[0].Label = "A label that is too long" },
+		func(h *Header) { h.Signals[0].SamplesRecord = 8 },
+		func(h *Header) { h.Version = BDFVersion },
+		func(h *Header) {
+			h.Signals = h.Signals[:1]
+			h.NumSignals = 1
+		},
+	}
+	for i, modify := range invalid {
+		h := *header
+		h.Signals = append([]SignalDefinition(nil), header.Signals...)
+		modify(&h)
+		if err := PatchHeader(f.Name(), &h); err == nil {
+			t.Errorf("Patch %d should fail", i)
+		}
+	}
+	actual, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, patched) {
+		t.Error("Failed patches should leave the file unchanged")
+	}
+}
+
+func TestBDFRoundTrip(t *testing.T) {
+	data, _ := testFile(2)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Header.Version = BDFVersion
+	e.Records[0].Signals[0].Samples[0] = 1<<23 - 1
+	e.Records[0].Signals[0].Samples[1] = -1 << 23
+	e.Records[1].Signals[1].Samples[0] = -70000
+
+	output := new(bytes.Buffer)
+	if err := Write(output, e); err != nil {
+		t.Fatal(err)
+	}
+	if output.Len() != 768+2*3*6 {
+		t.Errorf("BDF file is %d bytes long, should be %d", output.Len(), 768+2*3*6)
+	}
+	actual, err := Read(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !actual.Header.IsBDF() || !reflect.DeepEqual(actual.Records, e.Records) {
+		t.Errorf("%+v should be equal to %+v", actual, e)
+	}
+
+	e.Records[0].Signals[0].Samples[0] = 1 << 23
+	if err := Write(ioutil.Discard, e); err == nil {
+		t.Error("Writing a sample out of the 24-bit range should fail")
+	}
+}
+
+func TestWriteCalibration(t *testing.T) {
+	data, _ := testFile(1)
+	e, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Parsed values take precedence over raw strings not representing them.
+	e.Header.Signals[0].PhysMin = -123.456789
+	e.Header.Signals[1].DigiMax = 4095
+	e.Header.Signals[1].DigitalMinimum = ""
+	output := new(bytes.Buffer)
+	if err := Write(output, e); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := Read(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := actual.Header.Signals
+	if s[0].PhysicalMinimum != "-123.457" || s[0].PhysicalMaximum != "100" || s[1].DigitalMinimum != "-2048" || s[1].DigitalMaximum != "4095" {
+		t.Errorf("Unexpected signals %+v", s)
+	}
+}